@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"projectparty/nav"
+)
+
+// BotDifficulty holds how well a bot plays
+type BotDifficulty struct {
+	reaction    time.Duration // How long an enemy has to be in sight before the bot shoots at him
+	aimError    int           // Most degrees by which the bot misses his aim
+	dodgeChance float64       // Chance of the bot noticing a shot heading at him and stepping aside
+}
+
+// botDifficulties are the difficulties the host can choose from when adding a bot
+var botDifficulties = map[string]BotDifficulty{
+	"easy":   {600 * time.Millisecond, 20, 0.2},
+	"medium": {350 * time.Millisecond, 10, 0.5},
+	"hard":   {150 * time.Millisecond, 4, 0.85},
+}
+
+// Bot plays a player without a controller, its input is decided by the game itself at every step
+type Bot struct {
+	player     *Player
+	difficulty BotDifficulty
+
+	target    *Player   // Closest enemy in sight, nil if none is
+	seenSince time.Time // When the target came into sight
+
+	path     []nav.Point // Waypoints left on the way to the goal
+	pathTick uint64      // Tick at which the path was found
+
+	noticed    map[uint64]bool // Shots the bot has already decided whether to dodge
+	dodgeAngle int
+	dodgeUntil time.Time // Until when the bot steps aside, zero if it isn't dodging
+}
+
+// addBot processes the AddBot command sent by the host, adding a bot with the given difficulty like a controller joining
+func (g *Game) addBot(difficulty string) error {
+	level, ok := botDifficulties[difficulty]
+	if !ok {
+		return fmt.Errorf("unknown difficulty %s", difficulty)
+	}
+
+	nick := ""
+	for i := 1; nick == "" || !g.isNickAvailable(nick); i++ {
+		nick = fmt.Sprintf("Bot%d", i)
+	}
+	controller := &Controller{game: g, nick: nick, team: noTeam}
+	if err := g.addPlayer(controller); err != nil {
+		return err
+	}
+
+	player := g.players[controller]
+	g.bots[player] = &Bot{player: player, difficulty: level, noticed: make(map[uint64]bool)}
+	g.setReady(player, "1")
+	return nil
+}
+
+// updateBots queues the input of every bot for the next step, in id order so that the game stays reproducible
+func (g *Game) updateBots() {
+	if len(g.bots) == 0 {
+		return
+	}
+	if g.state != stateInRound {
+		for _, bot := range g.bots {
+			bot.path = nil // The next round is played on another map
+		}
+		return
+	}
+
+	shotsChan := make(chan []Shot)
+	g.shotBank.getShots <- GetShotsRequest{shotsChan}
+	shots := <-shotsChan
+
+	for _, player := range g.playersById() {
+		bot, ok := g.bots[player]
+		if !ok {
+			continue
+		}
+		if !player.alive {
+			bot.path = nil
+			continue
+		}
+		moveSpeed, moveAngle, shotAngle := bot.think(g, shots)
+		shotString := ""
+		if shotAngle >= 0 {
+			shotString = fmt.Sprint(shotAngle)
+		}
+		g.recorder.record("input", g.tick, ReplayInput{player.id, fmt.Sprintf("%d/%g:%d/%s", g.now().UnixNano(), moveSpeed, moveAngle, shotString)})
+		player.queueEvent(moveSpeed, moveAngle, shotAngle)
+	}
+}
+
+// think returns the input of the bot for the next step: moveSpeed, moveAngle and shotAngle (-1 for none)
+func (b *Bot) think(g *Game, shots []Shot) (float64, int, int) {
+	p := b.player
+	b.watchShots(g, shots)
+	b.pickTarget(g)
+
+	shotAngle := -1
+	if b.target != nil && g.now().Sub(b.seenSince) >= b.difficulty.reaction {
+		aimError := 0
+		if b.difficulty.aimError > 0 {
+			aimError = g.rng.Intn(2*b.difficulty.aimError+1) - b.difficulty.aimError
+		}
+		shotAngle = betterModulo(angleTo(p.xPos, p.yPos, b.target.xPos, b.target.yPos)+aimError, 360)
+	}
+
+	if g.now().Before(b.dodgeUntil) {
+		return 1, b.dodgeAngle, shotAngle
+	}
+
+	// Within range of an enemy in sight the bot circles him instead of walking straight into his shots
+	if b.target != nil && math.Hypot(b.target.xPos-p.xPos, b.target.yPos-p.yPos) < botEngageRange {
+		return 0.5, betterModulo(angleTo(p.xPos, p.yPos, b.target.xPos, b.target.yPos)+90, 360), shotAngle
+	}
+
+	goalX, goalY, ok := b.goal(g)
+	if !ok {
+		return 0, p.angle, shotAngle
+	}
+	if len(b.path) == 0 || g.tick-b.pathTick >= uint64(botRepathTime/refresh) {
+		b.path = g.mapData.navigation().Path(p.xPos, p.yPos, goalX, goalY)
+		b.pathTick = g.tick
+	}
+	for len(b.path) > 0 && math.Hypot(b.path[0].X-p.xPos, b.path[0].Y-p.yPos) < playerRadius {
+		b.path = b.path[1:]
+	}
+	if len(b.path) == 0 {
+		return 0, p.angle, shotAngle
+	}
+
+	return 1, angleTo(p.xPos, p.yPos, b.path[0].X, b.path[0].Y), shotAngle
+}
+
+// watchShots decides once for every shot heading at the bot whether it notices it, stepping aside if it does
+func (b *Bot) watchShots(g *Game, shots []Shot) {
+	p := b.player
+	flying := make(map[uint64]bool, len(shots))
+	for _, shot := range shots {
+		flying[shot.id] = true
+		if b.noticed[shot.id] || shot.owner == p || (g.areTeammates(shot.owner, p) && !g.settings.FriendlyFire) {
+			continue
+		}
+
+		// Distance of the bot along the flight of the shot and away from it
+		dirX, dirY := math.Cos(float64(shot.angle)*math.Pi/180), math.Sin(float64(shot.angle)*math.Pi/180)
+		relX, relY := p.xPos-shot.xPos, p.yPos-shot.yPos
+		along := relX*dirX + relY*dirY
+		across := dirX*relY - dirY*relX
+		if along <= 0 || along > botDodgeRange || math.Abs(across) > 2*playerRadius {
+			continue
+		}
+
+		b.noticed[shot.id] = true
+		if g.rng.Float64() >= b.difficulty.dodgeChance {
+			continue
+		}
+		// Step away from the line of the shot, to the side the bot already stands on
+		side := 90
+		if across < 0 {
+			side = -90
+		}
+		b.dodgeAngle = betterModulo(shot.angle+side, 360)
+		b.dodgeUntil = g.now().Add(botDodgeTime)
+	}
+
+	for id := range b.noticed {
+		if !flying[id] {
+			delete(b.noticed, id)
+		}
+	}
+}
+
+// pickTarget aims the bot at the closest living enemy in line of sight, restarting its reaction time whenever it changes
+func (b *Bot) pickTarget(g *Game) {
+	p := b.player
+	var target *Player
+	closest := math.Inf(1)
+	for _, other := range g.playersById() {
+		if other == p || !other.alive || g.areTeammates(p, other) {
+			continue
+		}
+		distance := math.Hypot(other.xPos-p.xPos, other.yPos-p.yPos)
+		if distance < closest && g.mapData.navigation().LineOfSight(p.xPos, p.yPos, other.xPos, other.yPos) {
+			target, closest = other, distance
+		}
+	}
+
+	if target != b.target {
+		b.seenSince = g.now()
+	}
+	b.target = target
+}
+
+// goal returns where the bot is heading: the hill in the king of the hill mode and the closest living enemy otherwise
+func (b *Bot) goal(g *Game) (float64, float64, bool) {
+	if koth, ok := g.mode.(*kingOfTheHill); ok && koth.hill != nil {
+		return koth.hill.xPos, koth.hill.yPos, true
+	}
+
+	p := b.player
+	var enemy *Player
+	closest := math.Inf(1)
+	for _, other := range g.playersById() {
+		if other == p || !other.alive || g.areTeammates(p, other) {
+			continue
+		}
+		if distance := math.Hypot(other.xPos-p.xPos, other.yPos-p.yPos); distance < closest {
+			enemy, closest = other, distance
+		}
+	}
+	if enemy == nil {
+		return 0, 0, false
+	}
+
+	return enemy.xPos, enemy.yPos, true
+}
+
+// angleTo returns the angle in whole degrees from (fromX, fromY) towards (toX, toY), in [0, 360)
+func angleTo(fromX, fromY, toX, toY float64) int {
+	return betterModulo(int(math.Round(math.Atan2(toY-fromY, toX-fromX)*180/math.Pi)), 360)
+}
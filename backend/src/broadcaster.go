@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Broadcaster fans the gameplay packets out to every screen watching the game, any number of them can connect
+// and leave at any time without affecting the game or each other
+type Broadcaster struct {
+	register   chan *Screen
+	unregister chan *Screen
+	messages   chan []byte
+}
+
+func NewBroadcaster() Broadcaster {
+	return Broadcaster{make(chan *Screen), make(chan *Screen), make(chan []byte)}
+}
+
+// Run owns the set of screens, it has to be running for the other methods to return
+func (b *Broadcaster) Run() {
+	screens := make(map[*Screen]bool)
+	for {
+		select {
+		case s := <-b.register:
+			screens[s] = true
+			log.Printf("screen connected, %d watching", len(screens))
+		case s := <-b.unregister:
+			if _, ok := screens[s]; ok {
+				delete(screens, s)
+				close(s.input)
+				log.Printf("screen disconnected, %d watching", len(screens))
+			}
+		case message := <-b.messages:
+			now := time.Now()
+			for s := range screens {
+				if !s.offerFrame(message, now) {
+					log.Printf("disconnecting a screen of game %d, it missed %d packets in a row", s.game.id, s.missed)
+					atomic.AddUint64(&metrics.SlowDisconnected, 1)
+					delete(screens, s)
+					close(s.input)
+				}
+			}
+		}
+	}
+}
+
+// broadcast queues the gameplay packet for every screen due one at its own rate, screens that don't keep up
+// skip to the latest one instead of slowing the game down
+func (b *Broadcaster) broadcast(message []byte) {
+	b.messages <- message
+}
@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"time"
 	"flag"
-	"math/rand"
 )
 
 // Constants for socket creations
@@ -17,13 +16,31 @@ const (
 	pongWait = 600 * time.Second // Time allowed to read the next pong message from the peer
 	pingPeriod = (pongWait * 9) / 10 // Send pings to peer with this period. Must be less than pongWait	
 	maxMessageSize = 512 // Maximum message size allowed from peer.
+	maxQueuedMessages = 256 // Event messages waiting for a peer before it's disconnected for falling behind
+	maxDroppedFrames = 200 // Gameplay packets in a row a screen can miss before it's disconnected for falling behind
+	rttProbePeriod = time.Second // How often screens are pinged to measure their round trip time
+	rateAdaptPeriod = time.Second // How often the rate of gameplay packets sent to each screen is adjusted
+	highRTT = 150 * time.Millisecond // Round trip time above which a screen receives packets less often
+	lowRTT = 50 * time.Millisecond // Round trip time below which a screen receives packets more often
 )
 
 // Constants for game events handling
 const (
-	fastRefresh = 15000000
-	slowRefresh = 100000000
-	refresh     = fastRefresh
+	fastRefresh     = 15000000    // Shortest time between two gameplay packets sent to a screen
+	slowRefresh     = 100000000   // Longest time between two gameplay packets sent to a screen
+	refresh         = fastRefresh // Time between two steps of the simulation, independent of the packets sent
+	maxCatchUpSteps = 5           // Most steps of the simulation done at once to catch up after the ticker fired late
+
+	replayVersion       = 1 // Version of the replay format, increased whenever it changes
+	replaySnapshotTicks = 4 // Every how many ticks the gameplay packet is written to the replay
+	maxReplayLine       = 4 * 1024 * 1024 // Longest record of a replay that can be played back, the maps being the longest ones
+	killCamLead         = 2 * time.Second // How long before a kill the kill cam starts
+	minReplaySpeed      = 0.25 // Slowest speed a replay can be played at
+	maxReplaySpeed      = 8.0  // Fastest speed a replay can be played at
+
+	simulationTickLimit = uint64(time.Hour / refresh) // Ticks after which a simulated match is stopped
+	scriptedShotRange   = 0.4 // How close an enemy has to be for a scripted player to shoot at him
+	scriptedAimError    = 10  // Most degrees by which a scripted player misses his aim
 	timeFactor  = float64(refresh) / 1000000000.0
 )
 
@@ -31,16 +48,79 @@ const (
 const (
 	globalShotSpeed = 110.0 / 800.0 * timeFactor // The speed at which the shot will travel
 	globalMoveSpeed = 80.0 / 800.0 * timeFactor // The speed at which players will walk
-	slowDown        = 3.5 * globalMoveSpeed // The speed of drag
+	slowDown        = 3.5 // The speed of drag, relative to the speed at which players walk
 
 	playerRadius = 0.015 // Player size, only code-wise
+	playerPush   = 0.2   // Extra share of an overlap added when separating two players, 0 disables pushing
 	unitSize     = 0.00125 // Size of one on-screen pixel for game event calculations
 
 	reloadTime     = 300 * time.Millisecond // Time between shots
 	roundBreakTime = 3 * time.Second // Time between the next round starts
 	maxRoundCount  = 5 // How many round are supposed to be played before the game end
+	roundTimeLimit = 60 * time.Second // How long a round lasts before sudden death begins
+	zoneShrinkTime = 30 * time.Second // How long it takes the sudden death zone to shrink to nothing
+	zoneDamage     = 0.5 * timeFactor // How much health a player outside of the zone loses every tick
+
+	mapWidth          = 52 // Width of the generated maps in cells
+	mapHeight         = 52 // Height of the generated maps in cells
+	mapFillPercentage = 46 // How many percent of the generated maps are initially filled with walls
 
 	lastManStandingPrize = 4 // How many points the winner receiver for being
+	drawMarker           = -1 // Sent in place of the winner id when a round ends with nobody alive
+
+	minPlayers = 2  // The least amount of players needed to start a game
+	maxPlayers = 16 // The most players that can join a single game
+
+	maxNickLength     = 32 // Longest nick a profile can have
+	profileTokenBytes = 16 // How many random bytes make up the device token of a profile
+
+	initialRating          = 1500.0 // Rating of players who haven't played a rated match yet
+	maxRatingDeviation     = 350.0  // Deviation of a rating nothing is known about
+	minRatingDeviation     = 30.0   // Deviation a rating never goes below, so that it can still change
+	ratingDecay            = 34.6   // How fast the deviation grows back per day without a match, back to the maximum in about 100 days
+	defaultLeaderboardSize = 20     // How many players a leaderboard lists unless asked otherwise
+	maxLeaderboardSize     = 100    // The most players a leaderboard can list
+
+	minTeamCount = 2  // The least amount of teams the host can set up
+	maxTeamCount = 4  // The most teams the host can set up
+	noTeam       = -1 // Team of players in the free-for-all mode
+
+	captureLimit     = 3                // How many captures win a capture the flag round
+	captureTimeLimit = 3 * time.Minute  // How long a capture the flag round lasts
+	capturePrize     = 5                // How many points a player receives for capturing a flag
+	flagRadius       = 2 * playerRadius // How close a player has to come to a flag or a base to touch it
+	flagReturnTime   = 20 * time.Second // How long a dropped flag lies before it returns to its base
+	respawnDelay     = 3 * time.Second  // How long a killed player waits before coming back in the modes with respawns
+	baseSpawnCount   = 10               // Among how many spawn points closest to their base players respawn
+
+	hillRadius     = 0.06             // Size of the king of the hill control zone
+	hillPointTime  = time.Second      // How long a player has to hold the hill alone to receive a point
+	hillMoveTime   = 30 * time.Second // How long the hill stays in one place
+	hillPointLimit = 30               // How many hill points win a king of the hill round
+	hillTimeLimit  = 3 * time.Minute  // How long a king of the hill round lasts
+
+	fragLimit           = 20              // How many kills win a deathmatch
+	deathmatchTimeLimit = 5 * time.Minute // How long a deathmatch lasts
+	spawnProtection     = 2 * time.Second // How long respawned players are immune to shots, unless they shoot first
+
+	maxSpawnCandidates = 48                 // How many spawn points, spread over the map, the spawn planner chooses from
+	spawnSightPenalty  = 0.5                // Factor of the distance between spawn points in sight of each other, telling them apart when no hidden one is left
+	spawnPlanPasses    = 3                  // Most rounds of improvements to the spread of the players at the start of a round
+	teamSpawnSpacing   = 3 * playerRadius   // How far apart teammates start from each other when there is enough room
+
+	botDodgeRange  = 0.15                   // How far away a shot heading at a bot can be noticed
+	botDodgeTime   = 250 * time.Millisecond // How long a bot steps aside from a shot it noticed
+	botEngageRange = 0.25                   // How close a bot comes to an enemy in sight before circling him
+	botRepathTime  = 500 * time.Millisecond // How often a bot looks for a new path to its goal
+)
+
+// Game modes the host can choose from
+const (
+	modeFreeForAll     = "ffa"
+	modeTeams          = "teams"
+	modeCaptureTheFlag = "ctf"
+	modeKingOfTheHill  = "koth"
+	modeDeathmatch     = "dm"
 )
 
 // Helpful declarations for websocket string creations
@@ -63,5 +143,3 @@ var currentId uint64 = 0
 
 // addr holds the required address flags for websocket communication
 var addr = flag.String("addr", ":8080", "http service address")
-
-var rng = rand.New(rand.NewSource(time.Now().Unix()))
\ No newline at end of file
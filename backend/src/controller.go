@@ -6,8 +6,12 @@ import (
 	"net/http"
 	"time"
 	"strconv"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gorilla/websocket"
+
+	"projectparty/store"
 )
 
 
@@ -15,7 +19,14 @@ import (
 type Controller struct {
 	game *Game
 	nick string
+	team int // Team chosen by the player, noTeam if he wants to be balanced automatically
+	profileRequest *profileRequest // Profile the player asked to join with, nil if he plays anonymously
+	profile *store.Profile // Profile the player joined with once the game admitted him, nil if he plays anonymously
 	conn *websocket.Conn
+
+	input chan []byte
+
+	disconnect sync.Once
 }
 
 // readPump pumps messages from the websocket connection to the game.
@@ -44,6 +55,56 @@ func (c *Controller) readPump() {
 	}
 }
 
+// writePump pumps messages from the game to the websocket connection.
+//
+// A goroutine running writePump is started for each connection. The
+// application ensures that there is at most one writer to a connection by
+// executing all writes from this goroutine.
+func (c *Controller) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case message, ok := <-c.input:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// The game closed the channel.
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// send queues an event message for the controller without ever blocking, every message is delivered in order
+// unless the controller falls too far behind, it is disconnected then
+func (c *Controller) send(message []byte) {
+	if c.conn == nil {
+		return // Bots and simulated players have nobody to send to
+	}
+	select {
+	case c.input <- message:
+		atomic.AddUint64(&metrics.EventsSent, 1)
+	default:
+		c.disconnect.Do(func() {
+			log.Printf("disconnecting controller %s, %d messages are waiting for it", c.nick, len(c.input))
+			atomic.AddUint64(&metrics.SlowDisconnected, 1)
+			c.conn.Close()
+		})
+	}
+}
+
 func serveControllerWs(w http.ResponseWriter, r *http.Request, games []*Game) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -67,14 +128,22 @@ func serveControllerWs(w http.ResponseWriter, r *http.Request, games []*Game) {
 		return
 	}
 
+	nick := ""
 	keys, ok = r.URL.Query()["nick"]
-	if !ok || len(keys) < 1 {
-		conn.WriteMessage(websocket.TextMessage, []byte("Error: nick is required"))
-		conn.WriteMessage(websocket.CloseMessage, []byte{})
-		return
+	if ok && len(keys) > 0 {
+		nick = keys[0]
 	}
 
-	nick := keys[0]
+	team := noTeam
+	keys, ok = r.URL.Query()["team"]
+	if ok && len(keys) > 0 {
+		team, err = strconv.Atoi(keys[0])
+		if err != nil {
+			conn.WriteMessage(websocket.TextMessage, []byte("Error: team must be an integer"))
+			conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		}
+	}
 
 	game := findGameById(gameId, games)
 
@@ -84,15 +153,25 @@ func serveControllerWs(w http.ResponseWriter, r *http.Request, games []*Game) {
 		return
 	}
 
-	if !game.isNickAvailable(nick) {
-		conn.WriteMessage(websocket.TextMessage, []byte("Error: nick is not available"))
+	request, err := parseProfileRequest(r.URL.Query())
+	if err != nil {
+		conn.WriteMessage(websocket.TextMessage, []byte("Error: "+err.Error()))
 		conn.WriteMessage(websocket.CloseMessage, []byte{})
 		return
 	}
+	if request != nil {
+		nick = request.name()
+	}
 
-	conn.WriteMessage(websocket.TextMessage, []byte("successful"))
+	if nick == "" {
+		conn.WriteMessage(websocket.TextMessage, []byte("Error: nick is required"))
+		conn.WriteMessage(websocket.CloseMessage, []byte{})
+		return
+	}
 
-	controller := &Controller{game: game, nick: nick, conn: conn}
+	// The game decides whether the controller can join and answers with either "successful" or an error
+	controller := &Controller{game: game, nick: nick, team: team, profileRequest: request, conn: conn, input: make(chan []byte, maxQueuedMessages)}
+	go controller.writePump()
 	controller.game.registerController <- controller
 
 	// Allow collection of memory referenced by the caller by doing all work in
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Flag is the flag of one of the teams in the capture the flag mode
+type Flag struct {
+	team      int
+	baseX     float64
+	baseY     float64
+	xPos      float64
+	yPos      float64
+	carrier   *Player   // The enemy carrying the flag, nil if it's lying somewhere
+	droppedAt time.Time // When the flag was dropped, zero if it's at its base or being carried
+}
+
+// atBase checks whether the flag lies untouched at its base
+func (f *Flag) atBase() bool {
+	return f.carrier == nil && f.xPos == f.baseX && f.yPos == f.baseY
+}
+
+// reset puts the flag back at its base
+func (f *Flag) reset() {
+	f.carrier = nil
+	f.xPos, f.yPos = f.baseX, f.baseY
+	f.droppedAt = time.Time{}
+}
+
+// String returns the flag in the format sent to the screen: team/xPos/yPos/carrierId
+func (f *Flag) String() string {
+	carrierId := -1
+	if f.carrier != nil {
+		carrierId = f.carrier.id
+	}
+
+	return fmt.Sprintf("%d/%f/%f/%d", f.team, f.xPos, f.yPos, carrierId)
+}
+
+// captureTheFlag is played by two teams which score by bringing the enemy flag to their own base,
+// killed players come back after a while and the round ends on the capture limit or when the time is up
+type captureTheFlag struct {
+	friendlyFire bool
+	flags        []*Flag // Flags of both teams, nil if the map has no place for the bases
+	captures     []int   // Flags captured by each team in the current round
+}
+
+// newCaptureTheFlag creates the mode from the "$friendlyFire" params
+func newCaptureTheFlag(params []string) (GameMode, error) {
+	if len(params) != 1 {
+		return nil, errors.New("capture the flag mode requires a friendly fire flag")
+	}
+
+	return &captureTheFlag{friendlyFire: params[0] == "1"}, nil
+}
+
+func (m *captureTheFlag) teams() (int, bool) {
+	return 2, m.friendlyFire
+}
+
+func (m *captureTheFlag) onRoundStart(g *Game) {
+	m.setupFlags(g)
+}
+
+func (m *captureTheFlag) spawnIndex(g *Game, p *Player) int {
+	return m.baseSpawnIndex(g, p)
+}
+
+func (m *captureTheFlag) onTick(g *Game) {
+	g.respawnDead()
+	m.updateFlags(g)
+}
+
+// onHit awards the shooter, killing a teammate costs a point
+func (m *captureTheFlag) onHit(g *Game, shooter, target *Player) {
+	if g.areTeammates(shooter, target) {
+		shooter.score--
+		return
+	}
+
+	shooter.score++
+	shooter.kills++
+}
+
+// onDeath drops the flag carried by the victim where he died
+func (m *captureTheFlag) onDeath(g *Game, victim *Player) {
+	for _, flag := range m.flags {
+		if flag.carrier == victim {
+			flag.carrier = nil
+			flag.xPos, flag.yPos = victim.xPos, victim.yPos
+			flag.droppedAt = g.now()
+			g.sendInfo([]byte(fmt.Sprintf("FlagDropped::%d/%d", flag.team, victim.id)))
+		}
+	}
+}
+
+func (m *captureTheFlag) isRoundOver(g *Game) bool {
+	_, over := m.checkRoundEnd(g)
+	return over
+}
+
+func (m *captureTheFlag) endRound(g *Game) {
+	team, _ := m.checkRoundEnd(g)
+	m.announceTeam(g, team)
+}
+
+func (m *captureTheFlag) isGameOver(g *Game) bool {
+	return g.roundCount >= g.settings.Rounds
+}
+
+func (m *captureTheFlag) finalStandings(g *Game) []Standing {
+	return g.teamStandings()
+}
+
+// objectives sends the flags as the fourth part of the gameplay packet
+// Correct message format (entries separated with a comma):
+// 		team/xPos/yPos/carrierId
+func (m *captureTheFlag) objectives(g *Game) string {
+	entries := make([]string, 0, len(m.flags))
+	for _, flag := range m.flags {
+		entries = append(entries, flag.String())
+	}
+
+	return "::" + strings.Join(entries, ",")
+}
+
+// setupFlags places the bases of both teams at the two spawn points which lie the farthest from each other
+func (m *captureTheFlag) setupFlags(g *Game) {
+	m.captures = make([]int, 2)
+	spawns := g.mapData.SpawnPoints
+	if len(spawns) < 2 {
+		m.flags = nil
+		return
+	}
+
+	first, second, farthest := 0, 1, 0.0
+	for i := range spawns {
+		for j := i + 1; j < len(spawns); j++ {
+			distance := math.Hypot(spawns[i].X-spawns[j].X, spawns[i].Y-spawns[j].Y)
+			if distance > farthest {
+				first, second, farthest = i, j, distance
+			}
+		}
+	}
+
+	m.flags = []*Flag{
+		{team: 0, baseX: spawns[first].X, baseY: spawns[first].Y},
+		{team: 1, baseX: spawns[second].X, baseY: spawns[second].Y},
+	}
+	for _, flag := range m.flags {
+		flag.reset()
+	}
+}
+
+// baseSpawnIndex picks one of the spawn points closest to the base of the player's team, preferring the ones hidden from enemies
+func (m *captureTheFlag) baseSpawnIndex(g *Game, p *Player) int {
+	spawns := g.mapData.SpawnPoints
+	if len(spawns) == 0 {
+		return -1 // Players are spawned anywhere they fit
+	}
+	if len(m.flags) <= p.team || p.team < 0 {
+		return g.rng.Intn(len(spawns))
+	}
+	base := m.flags[p.team]
+
+	indexes := make([]int, len(spawns))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return math.Hypot(spawns[indexes[i]].X-base.baseX, spawns[indexes[i]].Y-base.baseY) <
+			math.Hypot(spawns[indexes[j]].X-base.baseX, spawns[indexes[j]].Y-base.baseY)
+	})
+
+	nearest := baseSpawnCount
+	if nearest > len(indexes) {
+		nearest = len(indexes)
+	}
+
+	// Spawn points in sight of a living enemy are only used if all of them are
+	hidden := make([]int, 0, nearest)
+	for _, index := range indexes[:nearest] {
+		if !g.inEnemySight(p, spawns[index].X, spawns[index].Y) {
+			hidden = append(hidden, index)
+		}
+	}
+	if len(hidden) > 0 {
+		return hidden[g.rng.Intn(len(hidden))]
+	}
+	return indexes[g.rng.Intn(nearest)]
+}
+
+// updateFlags moves the carried flags, handles picking up, returning and capturing them
+func (m *captureTheFlag) updateFlags(g *Game) {
+	if g.roundStart.IsZero() {
+		return
+	}
+
+	for _, flag := range m.flags {
+		if flag.carrier != nil {
+			flag.xPos, flag.yPos = flag.carrier.xPos, flag.carrier.yPos
+			continue
+		}
+		if !flag.droppedAt.IsZero() && g.now().Sub(flag.droppedAt) >= flagReturnTime {
+			flag.reset()
+			g.sendInfo([]byte(fmt.Sprintf("FlagReturned::%d", flag.team)))
+			continue
+		}
+
+		for _, player := range g.playersById() {
+			if !player.alive || math.Hypot(player.xPos-flag.xPos, player.yPos-flag.yPos) > flagRadius {
+				continue
+			}
+			if player.team != flag.team && !m.isCarrying(player) {
+				flag.carrier = player
+				flag.droppedAt = time.Time{}
+				g.sendInfo([]byte(fmt.Sprintf("FlagTaken::%d/%d", flag.team, player.id)))
+				break
+			}
+			if player.team == flag.team && !flag.atBase() {
+				flag.reset()
+				g.sendInfo([]byte(fmt.Sprintf("FlagReturned::%d", flag.team)))
+				break
+			}
+		}
+	}
+
+	// A carrier scores by bringing the enemy flag to his own base while his own flag is there
+	for _, flag := range m.flags {
+		if flag.carrier == nil {
+			continue
+		}
+		home := m.flags[flag.carrier.team]
+		if home.atBase() && math.Hypot(flag.carrier.xPos-home.baseX, flag.carrier.yPos-home.baseY) <= flagRadius {
+			carrier := flag.carrier
+			carrier.score += capturePrize
+			m.captures[carrier.team]++
+			g.teamScores[carrier.team]++
+			flag.reset()
+			fmt.Fprintln(g.logger, "Player with id ", carrier.id, " captured the flag of team ", flag.team)
+			g.sendInfo([]byte(fmt.Sprintf("FlagCaptured::%d/%d", flag.team, carrier.id)))
+			g.sendInfo(g.getScoreBoardUpdate())
+		}
+	}
+}
+
+// isCarrying checks whether the player is carrying any flag
+func (m *captureTheFlag) isCarrying(player *Player) bool {
+	for _, flag := range m.flags {
+		if flag.carrier == player {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkRoundEnd reports whether a team has reached the capture limit or the time is up,
+// the team with more captures wins then and noTeam is returned on a draw
+func (m *captureTheFlag) checkRoundEnd(g *Game) (int, bool) {
+	if len(m.flags) < 2 {
+		return noTeam, true // There is no place for the bases on this map
+	}
+
+	for team, captures := range m.captures {
+		if captures >= captureLimit {
+			return team, true
+		}
+	}
+	if g.roundStart.IsZero() || g.now().Sub(g.roundStart) < g.settings.timeLimit(captureTimeLimit) {
+		return noTeam, false
+	}
+
+	if m.captures[0] > m.captures[1] {
+		return 0, true
+	} else if m.captures[1] > m.captures[0] {
+		return 1, true
+	}
+	return noTeam, true
+}
+
+// announceTeam awards and announces the team which won the capture the flag round
+// Correct message format:
+// 		EndRound::drawMarker::team, team being drawMarker on a draw
+func (m *captureTheFlag) announceTeam(g *Game, team int) {
+	if team == noTeam {
+		fmt.Fprintln(g.logger, "Sending info about end of capture the flag round with a draw")
+		g.sendEndRound(drawMarker, drawMarker)
+		return
+	}
+
+	g.teamRoundsWon[team]++
+	for _, player := range g.teamMembers(team) {
+		player.roundsWon++
+	}
+
+	fmt.Fprintln(g.logger, "Sending info about end of capture the flag round won by team ", team)
+	g.sendEndRound(drawMarker, team)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+)
+
+// deathmatch is played by individual players as a single long round, killed players come back after a while
+// and the match ends once someone reaches the frag limit or when the time is up
+type deathmatch struct{}
+
+func (m *deathmatch) teams() (int, bool) {
+	return 0, false
+}
+
+func (m *deathmatch) onRoundStart(g *Game) {}
+
+func (m *deathmatch) spawnIndex(g *Game, p *Player) int {
+	return g.spawns.index(g, p)
+}
+
+func (m *deathmatch) onTick(g *Game) {
+	g.respawnDead()
+}
+
+func (m *deathmatch) onHit(g *Game, shooter, target *Player) {
+	shooter.score++
+	shooter.kills++
+}
+
+func (m *deathmatch) onDeath(g *Game, victim *Player) {}
+
+func (m *deathmatch) isRoundOver(g *Game) bool {
+	_, over := m.checkEnd(g)
+	return over
+}
+
+func (m *deathmatch) endRound(g *Game) {
+	victor, _ := m.checkEnd(g)
+	m.announceWinner(g, victor)
+}
+
+// isGameOver always ends the game after the first round, as the whole match is played in one
+func (m *deathmatch) isGameOver(g *Game) bool {
+	return true
+}
+
+func (m *deathmatch) finalStandings(g *Game) []Standing {
+	return g.individualStandings()
+}
+
+func (m *deathmatch) objectives(g *Game) string {
+	return ""
+}
+
+// isProtected checks whether the player has respawned recently enough to be immune to shots
+func (p *Player) isProtected() bool {
+	return p.game.now().Before(p.protectedUntil)
+}
+
+// checkEnd reports whether a player has reached the frag limit or the time is up,
+// the player with the most kills wins then and nil is returned on a draw
+func (m *deathmatch) checkEnd(g *Game) (*Player, bool) {
+	var leader *Player
+	tied := false
+	for _, player := range g.playersById() {
+		if player.kills >= fragLimit {
+			return player, true
+		}
+		if leader == nil || player.kills > leader.kills {
+			leader, tied = player, false
+		} else if player.kills == leader.kills {
+			tied = true
+		}
+	}
+
+	if g.roundStart.IsZero() || g.now().Sub(g.roundStart) < g.settings.timeLimit(deathmatchTimeLimit) {
+		return nil, false
+	}
+	if tied {
+		return nil, true
+	}
+	return leader, true
+}
+
+// announceWinner announces the winner of the deathmatch, which is played as a single round
+func (m *deathmatch) announceWinner(g *Game, victor *Player) {
+	if victor == nil {
+		fmt.Fprintln(g.logger, "Sending info about end of deathmatch with a draw")
+		g.sendEndRound(drawMarker, drawMarker)
+		return
+	}
+
+	victor.roundsWon++
+	fmt.Fprintln(g.logger, "Sending info about end of deathmatch with victor with id ", victor.id)
+	g.sendEndRound(victor.id, drawMarker)
+}
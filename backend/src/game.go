@@ -4,20 +4,25 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
+	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"projectparty/store"
 )
 
 // Game holds all the necessary channels and attributes that allow us to handle the game flow
 type Game struct {
 	id          uint64               // Current game id
 	controllers map[*Controller]bool // Array of Controller pointers
-	screen      *Screen
+	screens     Broadcaster // Every screen and spectator watching the game
 	info        *GameInfo
 	roundCount  int // How many rounds have already been played this game
 
@@ -26,16 +31,39 @@ type Game struct {
 	registerController   chan *Controller
 	unregisterController chan *Controller
 
-	registerScreen   chan *Screen
-	unregisterScreen chan bool
-
 	registerGameInfo   chan *GameInfo
 	unregisterGameInfo chan bool
 
 	players    map[*Controller]*Player
+	nextPlayerId int // Id given to the next player who joins, ids aren't reused after a player leaves the lobby
+	bots       map[*Player]*Bot // Players controlled by the game itself
 	shotBank   ShotBank // Holds the ShotBank for the current round
 	shotsFired uint64
 	mapData    Map // // Holds the Map for the current round
+	spawns     *SpawnPlanner // Picks the spawn points on the map of the current round
+	seed       int64      // Seed of rng, the same seed and inputs reproduce the same game
+	rng        *rand.Rand // Source of every random choice in the game, owned by the game so that games don't share one
+
+	roundStart time.Time // When the current round started, zero during breaks
+	state      GameState // Stage of the game flow, changed only through setState
+	tick       uint64    // How many steps of the simulation have been done
+	clock      time.Time // Time of the game, moved forward by refresh with every step of the simulation
+	lag        time.Duration // Real time the simulation has yet to catch up with
+	nextRound  time.Time // When the upcoming round begins, zero if none is upcoming
+	mapSeed    int32        // Seed the map of the current round was generated with
+	history    *store.Match // History of the game once it starts, saved to storage when it ends
+	recorder   *Recorder // Writes the replay of the game once it starts, nil if it isn't recorded
+	loadMap    func(settings Settings, seed int32) (Map, error) // Source of the maps, the map service unless the game runs headless
+
+	infoMessages chan []byte // Commands sent by the host over the game info socket
+	logger       io.Writer   // Where the game logs what happens in it
+
+	mode          GameMode // Rules of the game, lastManStanding by default
+	modeName      string   // Params of the Mode command which set the mode up
+	settings      Settings // Rules of the game chosen by the host
+	teamCount     int      // How many teams play in this game, 0 if the mode has no teams
+	teamScores    []int
+	teamRoundsWon []int
 }
 
 // ControllerMessage allows for better message handling between the Game and the Controller
@@ -44,22 +72,39 @@ type ControllerMessage struct {
 	message []byte
 }
 
-// loadMap performs an HTTP request to the map service and returns a Map structure
-func loadMap() (Map, error) {
-	response, err := http.Get("http://map:3000/generate?width=52&height=52&fillPercentage=46")
+// loadMap performs an HTTP request to the map service and returns a Map structure, the same seed always giving the same map
+func loadMap(settings Settings, seed int32) (Map, error) {
+	response, err := http.Get(fmt.Sprintf("http://map:3000/generate?width=%d&height=%d&fillPercentage=%d&seed=%d", settings.MapWidth, settings.MapHeight, settings.MapFill, seed))
 	if err != nil {
 		return Map{}, err
 	}
+	defer response.Body.Close()
 
-	data, _ := ioutil.ReadAll(response.Body)
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return Map{}, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return Map{}, fmt.Errorf("map service answered with status %d", response.StatusCode)
+	}
 
-	return createMapFromJson(data), nil
+	loadedMap := createMapFromJson(data)
+	if loadedMap.ErrorInfo != nil {
+		return Map{}, fmt.Errorf("map service failed: %v", loadedMap.ErrorInfo)
+	}
+	if len(loadedMap.SpawnPoints) == 0 {
+		return Map{}, errors.New("map has no spawn points")
+	}
+
+	return loadedMap, nil
 }
 
-// newGame returns the reference to the new game
-func newGame() (*Game, error) {
+// newGame returns the reference to the new game, which logs to logger
+func newGame(logger io.Writer) (*Game, error) {
 	newId := currentId
 	currentId++
+	seed := time.Now().UnixNano()
+	fmt.Fprintln(logger, "Game with id ", newId, " uses seed ", seed)
 
 	return &Game{
 		id:                   newId,
@@ -67,14 +112,24 @@ func newGame() (*Game, error) {
 		controllerMessages:   make(chan *ControllerMessage),
 		registerController:   make(chan *Controller),
 		unregisterController: make(chan *Controller),
-		registerScreen:       make(chan *Screen),
-		unregisterScreen:     make(chan bool),
+		screens:              NewBroadcaster(),
 		registerGameInfo:     make(chan *GameInfo),
 		unregisterGameInfo:   make(chan bool),
+		infoMessages:         make(chan []byte),
 		players:              make(map[*Controller]*Player),
+		bots:                 make(map[*Player]*Bot),
 		shotBank:             NewShotBank(),
 		shotsFired:           0,
 		roundCount:           0,
+		seed:                 seed,
+		rng:                  rand.New(rand.NewSource(seed)),
+		state:                stateLobby,
+		clock:                time.Now(),
+		mode:                 &lastManStanding{},
+		modeName:             modeFreeForAll,
+		loadMap:              loadMap,
+		settings:             defaultSettings(),
+		logger:               logger,
 	}, nil
 }
 
@@ -108,7 +163,9 @@ func (g *Game) getPlayerPositions() string {
 	if len(g.players) > 0 {
 		for i := range g.players {
 			currPlayer := g.players[i]
-			if currPlayer.alive {
+			if currPlayer.alive && g.teamCount > 0 {
+				result += fmt.Sprintf("%d/%f/%f/%d/%d,", currPlayer.id, currPlayer.xPos, currPlayer.yPos, currPlayer.angle, currPlayer.team)
+			} else if currPlayer.alive {
 				result += fmt.Sprintf("%d/%f/%f/%d,", currPlayer.id, currPlayer.xPos, currPlayer.yPos, currPlayer.angle)
 			}
 		}
@@ -146,95 +203,244 @@ func (g *Game) getShotPositions() string {
 // round() starts another round in the game, including loading a new map and reseting player positions
 func (g *Game) round() {
 	g.roundCount++ // Increment the round count var
+	g.roundStart = time.Time{}
 
 	// Grab new map data
-	loadedMap, err := loadMap()
+	g.mapSeed = g.rng.Int31()
+	loadedMap, err := g.loadMap(g.settings, g.mapSeed)
 	if err != nil {
-		fmt.Printf("The HTTP request to grab map data failed with error %s\n", err)
+		fmt.Fprintf(g.logger, "The HTTP request to grab map data failed with error %s\n", err)
+		g.sendInfo([]byte("Error::could not load the map"))
+		g.roundCount-- // The round is never played
+		if g.state == stateCountdown {
+			g.recorder.close(g.tick)
+			g.recorder = nil
+			g.history = nil
+			g.setState(stateLobby)
+		} else {
+			g.endGame()
+		}
 		return
 	}
 	g.mapData = loadedMap
+	g.spawns = newSpawnPlanner(g)
+	g.mode.onRoundStart(g)
 
 	// Reset shot count
+	g.shotBank.Stop()
 	g.shotBank = NewShotBank()
 	go g.shotBank.Run()
 
-	// Send new round info
-	go func() {
-		time.Sleep(roundBreakTime)
+	// The simulation begins the round once the break is over
+	g.nextRound = g.now().Add(g.settings.roundBreak())
+}
 
-		// Update player positions and respawn
-		for i := range g.players {
-			currPlayer := g.players[i]
-			currPlayer.respawn()
-		}
+// beginRound() respawns the players and sends the new round info once the break before the round is over
+func (g *Game) beginRound() {
+	g.nextRound = time.Time{}
 
-		messageToSend := []byte(fmt.Sprintf("NewRound::%s::", g.getPlayerPositions()))
-		messageToSend = append(messageToSend, createJsonFromMap(g.mapData)...)
-		g.info.input <- messageToSend
-		go processEvents(g)
-		return
-	}()
+	g.resetRoundStats()
+
+	// Update player positions and respawn, always in the same order so that the same seed gives the same spawns
+	for _, currPlayer := range g.playersById() {
+		currPlayer.respawn()
+	}
+
+	messageToSend := []byte(fmt.Sprintf("NewRound::%s::", g.getPlayerPositions()))
+	messageToSend = append(messageToSend, createJsonFromMap(g.mapData)...)
+	g.sendInfo(messageToSend)
+	g.recorder.record("round", g.tick, ReplayRound{g.roundCount, createJsonFromMap(g.mapData)})
+	g.setState(stateInRound)
+	g.roundStart = g.now()
 }
 
-// endGame() finds the winner of the whole game and sends a message to the screen websocket
+// now() returns the time of the game, which only moves forward with the simulation
+func (g *Game) now() time.Time {
+	return g.clock
+}
+
+// endGame() finds the winners of the whole game and sends a message to the screen websocket
+// The order is decided by the game mode, if several players or teams share the first place the victory is shared
+// and the nicks are sent separated by commas, followed by the winning teams in the modes with teams
 func (g *Game) endGame() {
-	g.roundCount++
-	highScore := 0
-	currWinner := ""
-	for _, player := range g.players {
-		if player.score > highScore {
-			highScore = player.score
-			currWinner = player.nick
+	if err := g.setState(stateFinished); err != nil {
+		fmt.Fprintln(g.logger, err)
+		return
+	}
+	defer g.recorder.close(g.tick)
+	g.shotBank.Stop()
+
+	score := 0
+	nicks := make([]string, 0)
+	teams := make([]string, 0)
+	standings := g.mode.finalStandings(g)
+	g.saveHistory(standings)
+	for _, standing := range standings {
+		if standing.place != 1 {
+			break
+		}
+		score = standing.score
+		for _, player := range standing.players {
+			nicks = append(nicks, player.nick)
+		}
+		if standing.team != noTeam {
+			teams = append(teams, strconv.Itoa(standing.team))
 		}
 	}
-	g.info.input <- []byte(fmt.Sprintf("EndGame::%d/%s", highScore, currWinner))
+
+	if g.teamCount > 0 {
+		g.sendInfo([]byte(fmt.Sprintf("EndGame::%d/%s::%s", score, strings.Join(nicks, ","), strings.Join(teams, ","))))
+	} else {
+		g.sendInfo([]byte(fmt.Sprintf("EndGame::%d/%s", score, strings.Join(nicks, ","))))
+	}
+	g.sendStats("game", g.gameStats())
+}
+
+// addPlayer(controller *Controller) creates the player of a controller which has just connected, unless it can't join the game
+func (g *Game) addPlayer(controller *Controller) error {
+	if err := g.admitController(controller); err != nil {
+		return err
+	}
+	if controller.profileRequest != nil {
+		profile, err := controller.profileRequest.join()
+		if err != nil {
+			return err
+		}
+		controller.profile = profile
+	}
+
+	controller.send([]byte("successful"))
+	if controller.profile != nil {
+		controller.send([]byte(fmt.Sprintf("Profile::%d/%s", controller.profile.Id, controller.profile.Token)))
+	}
+	g.controllers[controller] = true
+	newPlayer := NewPlayer(g, controller.nick, 0, 0)
+	newPlayer.preferredTeam = controller.team
+	newPlayer.profile = controller.profile
+	g.joinTeam(newPlayer)
+	g.players[controller] = newPlayer
+	controller.send(g.getSettingsUpdate())
+	g.sendInfo(g.getNewPlayer(newPlayer))
+	fmt.Fprintln(g.logger, "Sent information regarding new player of id ", newPlayer.id)
+	return nil
+}
+
+// removePlayer(controller *Controller) lets the player of a controller which has disconnected in the lobby leave the game,
+// freeing his nick and his place in the game and his team. Players who leave once the game has started stay in it
+func (g *Game) removePlayer(controller *Controller) {
+	player, ok := g.players[controller]
+	if !ok || g.state != stateLobby {
+		return
+	}
+
+	delete(g.players, controller)
+	g.sendInfo([]byte(fmt.Sprintf("PlayerLeft::%d", player.id)))
+	fmt.Fprintln(g.logger, "Player with id ", player.id, " left the lobby")
 }
 
-// run() handles communication between websockets and the flow of the game setup
+// run() owns the game: it handles communication between websockets, the flow of the game setup and the simulation,
+// so that the state of the game is only ever touched by this goroutine
 func (g *Game) run() {
 	go g.shotBank.Run()
-	go processGameEvents(g)
+	go g.screens.Run()
+
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	ticks := ticker.C
+	last := time.Now()
 	for {
 		select {
 		case controller := <-g.registerController:
-			if g.roundCount == 0 {
-				g.controllers[controller] = true
-				newPlayer := NewPlayer(g, controller.nick, 0, 0)
-				g.players[controller] = newPlayer
-				select {
-				case g.info.input <- []byte(fmt.Sprintf("NewPlayer::%d/%s", newPlayer.id, newPlayer.nick)):
-					fmt.Println("Sent information regarding new player of id ", newPlayer.id)
-				default:
-					fmt.Println("huh")
-				}
+			if err := g.addPlayer(controller); err != nil {
+				controller.send([]byte("Error: " + err.Error()))
+				close(controller.input)
 			}
 		case controller := <-g.unregisterController:
 			if _, ok := g.controllers[controller]; ok {
 				delete(g.controllers, controller)
 			}
-		case screen := <-g.registerScreen:
-			if len(g.players) >= 2 {
-				if g.screen == nil {
-					g.screen = screen
-				}
-				g.round()
-			}
-		case <-g.unregisterScreen:
-			g.screen = nil
+			g.removePlayer(controller)
 		case info := <-g.registerGameInfo:
 			if g.info == nil {
 				g.info = info
-				messageToSend := []byte(fmt.Sprintf("NewGame::%d", g.id))
-				g.info.input <- messageToSend
+				messageToSend := []byte(fmt.Sprintf("NewGame::%d::%d", g.id, g.seed))
+				g.sendInfo(messageToSend)
 			}
 		case <-g.unregisterGameInfo:
 			g.info = nil
+		case message := <-g.infoMessages:
+			g.processHostMessage(string(message))
 		case cMessage := <-g.controllerMessages:
+			currPlayer, ok := g.players[cMessage.c]
+			if !ok {
+				break // The controller wasn't admitted to the game
+			}
+			if ready := strings.TrimPrefix(string(cMessage.message), "Ready::"); ready != string(cMessage.message) {
+				g.setReady(currPlayer, ready)
+				break
+			}
+			if g.state != stateInRound {
+				break // Players can neither move nor shoot before the round starts
+			}
 			shotAngle, moveSpeed, moveAngle := processPlayerMessage(string(cMessage.message))
-			currPlayer := g.players[cMessage.c]
+			g.recorder.record("input", g.tick, ReplayInput{currPlayer.id, string(cMessage.message)})
 			currPlayer.queueEvent(moveSpeed, moveAngle, shotAngle)
+		case now := <-ticks:
+			g.catchUp(now.Sub(last))
+			last = now
+			if g.state == stateFinished {
+				ticks = nil // Nothing is simulated once the game has ended
+			}
+		}
+	}
+}
+
+// processHostMessage(message string) processes commands sent by the host over the game info socket
+// The host sends commands in the same format as the ones he receives: "${command}::${params}"
+// Mode - "ffa", "teams/${teamCount}/${friendlyFire}", "ctf/${friendlyFire}", "koth" or "dm", friendlyFire being 0 or 1
+// Settings - JSON object with any of the fields of Settings, echoed back to every client once applied
+// Start - empty to start once every player is ready or "force" to start regardless, only in the lobby
+// AddBot - difficulty of a bot joining the game like another player, "easy", "medium" or "hard", only in the lobby
+func (g *Game) processHostMessage(message string) {
+	parts := strings.SplitN(message, "::", 2)
+	if len(parts) != 2 {
+		g.sendInfo([]byte("Error::wrong message format"))
+		return
+	}
+
+	switch parts[0] {
+	case "Mode":
+		if err := g.setMode(parts[1]); err != nil {
+			g.sendInfo([]byte("Error::" + err.Error()))
+			return
+		}
+		g.sendInfo([]byte(message))
+		g.sendInfo(g.getTeamsUpdate())
+		g.broadcast(g.getSettingsUpdate())
+	case "Settings":
+		if err := g.setSettings(parts[1]); err != nil {
+			g.sendInfo([]byte("Error::" + err.Error()))
+			return
 		}
+		g.broadcast(g.getSettingsUpdate())
+	case "Start":
+		if err := g.start(parts[1]); err != nil {
+			g.sendInfo([]byte("Error::" + err.Error()))
+		}
+	case "AddBot":
+		if err := g.addBot(parts[1]); err != nil {
+			g.sendInfo([]byte("Error::" + err.Error()))
+		}
+	default:
+		g.sendInfo([]byte("Error::unknown command " + parts[0]))
+	}
+}
+
+// broadcast(message []byte) sends the message to the game info socket and to every controller, without ever blocking
+func (g *Game) broadcast(message []byte) {
+	g.sendInfo(message)
+	for controller := range g.controllers {
+		controller.send(message)
 	}
 }
 
@@ -280,109 +486,156 @@ func processPlayerMessage(message string) (int, float64, int) {
 	return -1, -1, -1
 }
 
-// processGameEvents(g *Game) handles calculating various game processes, including new positions of the players and shots
-func processGameEvents(g *Game) {
-	for range time.Tick(time.Nanosecond * refresh) {
-		for i := range g.players {
-			currPlayer := g.players[i]
-			currPlayer.processLastEvent()
+// catchUp(elapsed time.Duration) drives the simulation in fixed steps of refresh, independent of how late the ticker fires:
+// the steps missed by a late tick are done at once, but never more than maxCatchUpSteps so that a long pause doesn't stall the game.
+// The screens are sent the round as it is after the steps
+func (g *Game) catchUp(elapsed time.Duration) {
+	g.lag += elapsed
+	for steps := 0; g.lag >= refresh && g.state != stateFinished; steps++ {
+		if steps == maxCatchUpSteps {
+			fmt.Fprintln(g.logger, "Game with id ", g.id, " fell behind, skipping ", g.lag/refresh, " steps")
+			g.lag = 0
+			break
 		}
+		g.step()
+		g.lag -= refresh
+	}
 
-		g.shotBank.moveShots <- true
+	if g.state == stateInRound {
+		g.screens.broadcast([]byte(g.getGameplayPacket()))
+	}
+}
 
-		shotsChan := make(chan []Shot)
-		g.shotBank.getShots <- GetShotsRequest{shotsChan}
-		shots := <-shotsChan
+// step() advances the game by a single tick, including new positions of the players and shots, hits and the end of rounds
+func (g *Game) step() {
+	g.tick++
+	g.clock = g.clock.Add(refresh)
+	if !g.nextRound.IsZero() && !g.clock.Before(g.nextRound) {
+		g.beginRound()
+	}
 
-		for _, currShot := range shots {
-			if currShot.xPos >= 1 || currShot.xPos <= 0 || currShot.yPos >= 1 || currShot.yPos <= 0 {
-				g.shotBank.deleteShot <- currShot.id
+	g.updateBots()
+	if g.state == stateInRound { // Players in the lobby or waiting for a round stand still
+		for _, currPlayer := range g.playersById() {
+			xPos, yPos := currPlayer.xPos, currPlayer.yPos
+			currPlayer.processLastEvent()
+			if currPlayer.alive {
+				currPlayer.stats.live(refresh, math.Hypot(currPlayer.xPos-xPos, currPlayer.yPos-yPos))
 			}
+		}
+	}
+	g.separatePlayers()
+	g.mode.onTick(g)
 
-			for _, wall := range g.mapData.Walls {
-				for i := 0; i < len(wall)-1; i += 2 {
-					xPosA := wall[i]
-					yPosA := wall[i+1]
-					xPosB := wall[(i+2)%len(wall)]
-					yPosB := wall[(i+3)%len(wall)]
-					if g.mapData.lineCircleCollision(xPosA, yPosA, xPosB, yPosB, currShot.xPos, currShot.yPos, unitSize) {
-						g.shotBank.deleteShot <- currShot.id
-					}
+	g.shotBank.moveShots <- true
+
+	shotsChan := make(chan []Shot)
+	g.shotBank.getShots <- GetShotsRequest{shotsChan}
+	shots := <-shotsChan
+
+	for _, currShot := range shots {
+		if currShot.xPos >= 1 || currShot.xPos <= 0 || currShot.yPos >= 1 || currShot.yPos <= 0 {
+			g.shotBank.deleteShot <- currShot.id
+		}
+
+		for _, wall := range g.mapData.Walls {
+			for i := 0; i < len(wall)-1; i += 2 {
+				xPosA := wall[i]
+				yPosA := wall[i+1]
+				xPosB := wall[(i+2)%len(wall)]
+				yPosB := wall[(i+3)%len(wall)]
+				if g.mapData.lineCircleCollision(xPosA, yPosA, xPosB, yPosB, currShot.xPos, currShot.yPos, unitSize) {
+					g.shotBank.deleteShot <- currShot.id
 				}
 			}
 		}
+	}
 
+	if g.state == stateInRound { // Shots left over from the round can't hit anybody during a break
 		for _, currShot := range shots {
-			for i := range g.players {
-				currPlayer := g.players[i]
+			counted := false // A shot striking several players at once is a single hit in the stats of its owner
+			for _, currPlayer := range g.playersById() {
 				if math.Abs(currShot.xPos-currPlayer.xPos) < playerRadius && math.Abs(currShot.yPos-currPlayer.yPos) < playerRadius && currShot.owner.id != currPlayer.id && currPlayer.alive {
+					teammates := g.areTeammates(currShot.owner, currPlayer)
+					if teammates && !g.settings.FriendlyFire {
+						continue // Shots fly through teammates
+					}
+					if !teammates && !counted {
+						currShot.owner.stats.hit(math.Hypot(currPlayer.xPos-currShot.xFrom, currPlayer.yPos-currShot.yFrom))
+						counted = true
+					}
+					if currPlayer.isProtected() {
+						g.shotBank.deleteShot <- currShot.id
+						continue
+					}
+					if teammates {
+						currShot.owner.stats.teamKill()
+					} else {
+						currShot.owner.stats.kill()
+					}
+					g.recorder.record("kill", g.tick, ReplayKill{currShot.owner.id, currPlayer.id})
+					g.addKill(currShot.owner, currPlayer)
+					g.mode.onHit(g, currShot.owner, currPlayer)
 					currPlayer.kill()
-					currShot.owner.score++
-					g.info.input <- g.getScoreBoardUpdate()
+					g.sendInfo(g.getScoreBoardUpdate())
 					g.shotBank.deleteShot <- currShot.id
-					fmt.Println("Played with id ", currPlayer.id, " killed")
+					fmt.Fprintln(g.logger, "Played with id ", currPlayer.id, " killed")
 				}
 			}
 		}
+	}
 
-		if g.roundCount > maxRoundCount {
-			return
-		}
+	if g.state == stateInRound && g.tick%replaySnapshotTicks == 0 {
+		g.recorder.record("snapshot", g.tick, g.getGameplayPacket())
 	}
-}
 
-// checkRoundEnd() returns the winner of the current round, nil if it's still ongoing
-func (g *Game) checkRoundEnd() *Player {
-	var victorAlive *Player = nil
-	for _, currPlayer := range g.players {
-		// currPlayer := g.players[i]
-		if currPlayer.alive && victorAlive == nil {
-			victorAlive = currPlayer
-		} else if currPlayer.alive && victorAlive != nil {
-			return nil
+	if g.state == stateInRound && g.mode.isRoundOver(g) {
+		g.mode.endRound(g)
+		if !g.mode.isGameOver(g) {
+			g.setState(stateRoundBreak)
+			g.round()
+		} else {
+			g.endGame()
 		}
 	}
-	return victorAlive
 }
 
 // getScoreBoardUpdate() sends new score information to the screen websocket in case a player has been
 func (g *Game) getScoreBoardUpdate() []byte {
 	result := []byte("ScoreboardUpdate::")
 	for _, player := range g.players {
-		result = append(result, []byte(fmt.Sprintf("%d/%d,", player.id, player.score))...)
+		if g.teamCount > 0 {
+			result = append(result, []byte(fmt.Sprintf("%d/%d/%d,", player.id, player.score, player.team))...)
+		} else {
+			result = append(result, []byte(fmt.Sprintf("%d/%d,", player.id, player.score))...)
+		}
 	}
 	if len(result) > 0 {
 		result = result[:len(result)-1]
 	}
+	if g.teamCount > 0 {
+		result = append(result, []byte("::"+g.getTeamScores())...)
+	}
 
 	return result
 }
 
-// processEvents(g *Game) handles communication with the screen websocket, sending data regarding new player and shots positions and similar
-func processEvents(g *Game) {
-	keepProcessing := true
-	for range time.Tick(time.Nanosecond * fastRefresh) {
-		if g.screen != nil && keepProcessing {
-			victor := g.checkRoundEnd()
-			if victor != nil {
-				keepProcessing = false
-				fmt.Println("Sending info about end of round with victor with id ", victor.id)
-				victor.score += lastManStandingPrize
-				g.info.input <- g.getScoreBoardUpdate()
-				g.info.input <- []byte(fmt.Sprintf("EndRound::%d", victor.id))
-				if g.roundCount < maxRoundCount {
-					g.round()
-				} else {
-					g.endGame()
-				}
-				return
-			}
-			updateString := g.getPlayerPositions()
-			updateString += ":"
-			updateString += g.getShotPositions()
-			g.screen.input <- []byte(updateString)
-		}
-	}
+// getSnapshotTime(packet string) returns the last part of the gameplay packet, which lets the screens interpolate between packets,
+// the missing optional parts are filled in so it is always the fifth one
+// Correct message format:
+// 		:tick/timestamp, timestamp being the milliseconds since Unix EPOCH when the simulation step was done
+func (g *Game) getSnapshotTime(packet string) string {
+	result := strings.Repeat(":", 3-strings.Count(packet, ":"))
+	return result + fmt.Sprintf(":%d/%d", g.tick, g.clock.UnixNano()/int64(time.Millisecond))
+}
+
+// getGameplayPacket() creates the gameplay packet sent to the screens, showing the current state of the round
+func (g *Game) getGameplayPacket() string {
+	updateString := g.getPlayerPositions()
+	updateString += ":"
+	updateString += g.getShotPositions()
+	updateString += g.mode.objectives(g)
+	return updateString + g.getSnapshotTime(updateString)
 }
 
 // Abs(x int64) is a helper function to calculate the absolute value of an integer
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -16,6 +19,33 @@ type GameInfo struct {
 	conn *websocket.Conn
 
 	input chan []byte
+
+	disconnect sync.Once
+}
+
+// readPump pumps commands sent by the host from the websocket connection to the game.
+//
+// The application runs readPump in a per-connection goroutine. The application
+// ensures that there is at most one reader on a connection by executing all
+// reads from this goroutine.
+func (s *GameInfo) readPump() {
+	defer func() {
+		s.conn.Close()
+	}()
+	s.conn.SetReadLimit(maxMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error { s.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("error: %v", err)
+			}
+			break
+		}
+		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
+		s.game.infoMessages <- message
+	}
 }
 
 // writePump pumps messages from the game to the websocket connection.
@@ -52,16 +82,36 @@ func (s *GameInfo) writePump() {
 	}
 }
 
+// send queues an event message for the host without ever blocking, every message is delivered in order
+// unless the host falls too far behind, he is disconnected then
+func (s *GameInfo) send(message []byte) {
+	if s == nil {
+		return // The host has left
+	}
+
+	select {
+	case s.input <- message:
+		atomic.AddUint64(&metrics.EventsSent, 1)
+	default:
+		s.disconnect.Do(func() {
+			log.Printf("disconnecting the host of game %d, %d messages are waiting for him", s.game.id, len(s.input))
+			atomic.AddUint64(&metrics.SlowDisconnected, 1)
+			s.conn.Close()
+		})
+	}
+}
+
 func serveGameInfoWs(game *Game, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	gameInfo := &GameInfo{game: game, conn: conn, input: make(chan []byte, 256)}
+	gameInfo := &GameInfo{game: game, conn: conn, input: make(chan []byte, maxQueuedMessages)}
 	gameInfo.game.registerGameInfo <- gameInfo
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
 	go gameInfo.writePump()
+	go gameInfo.readPump()
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Hill is the control zone of the king of the hill mode, players score by standing in it alone
+type Hill struct {
+	xPos      float64
+	yPos      float64
+	owner     *Player   // The only living player inside of the hill, nil if it's empty or contested
+	contested bool      // Whether more than one living player stands inside of the hill
+	progress  float64   // How far the owner is on the way to the next point, in [0, 1)
+	placedAt  time.Time // When the hill was moved to its current position
+}
+
+// String returns the hill in the format sent to the screen: xPos/yPos/radius/ownerId/progress/contested
+func (h *Hill) String() string {
+	ownerId := -1
+	if h.owner != nil {
+		ownerId = h.owner.id
+	}
+	contested := 0
+	if h.contested {
+		contested = 1
+	}
+
+	return fmt.Sprintf("%f/%f/%f/%d/%f/%d", h.xPos, h.yPos, hillRadius, ownerId, h.progress, contested)
+}
+
+// kingOfTheHill is played by individual players who score by holding the hill alone, killed players come back after a while
+// and the round ends once someone collects enough hill points or when the time is up
+type kingOfTheHill struct {
+	hill   *Hill
+	points map[*Player]int // Points scored for holding the hill in the current round
+}
+
+func newKingOfTheHill() *kingOfTheHill {
+	return &kingOfTheHill{points: make(map[*Player]int)}
+}
+
+func (m *kingOfTheHill) teams() (int, bool) {
+	return 0, false
+}
+
+func (m *kingOfTheHill) onRoundStart(g *Game) {
+	m.hill = nil
+	m.points = make(map[*Player]int)
+}
+
+func (m *kingOfTheHill) spawnIndex(g *Game, p *Player) int {
+	return g.spawns.index(g, p)
+}
+
+func (m *kingOfTheHill) onTick(g *Game) {
+	g.respawnDead()
+	m.updateHill(g)
+}
+
+func (m *kingOfTheHill) onHit(g *Game, shooter, target *Player) {
+	shooter.score++
+	shooter.kills++
+}
+
+func (m *kingOfTheHill) onDeath(g *Game, victim *Player) {}
+
+func (m *kingOfTheHill) isRoundOver(g *Game) bool {
+	_, over := m.checkRoundEnd(g)
+	return over
+}
+
+func (m *kingOfTheHill) endRound(g *Game) {
+	victor, _ := m.checkRoundEnd(g)
+	g.announceRoundWinner(victor, g.settings.Prize)
+}
+
+func (m *kingOfTheHill) isGameOver(g *Game) bool {
+	return g.roundCount >= g.settings.Rounds
+}
+
+func (m *kingOfTheHill) finalStandings(g *Game) []Standing {
+	return g.individualStandings()
+}
+
+// objectives sends the hill as the fourth part of the gameplay packet
+func (m *kingOfTheHill) objectives(g *Game) string {
+	if m.hill == nil {
+		return ""
+	}
+	return "::" + m.hill.String()
+}
+
+// placeHill puts the hill in a random open spot of the map, different from its current position if possible
+func (m *kingOfTheHill) placeHill(g *Game) {
+	xPos, yPos := g.mapData.randomOpenPoint(g.rng)
+	for tries := 0; m.hill != nil && tries < 10 && xPos == m.hill.xPos && yPos == m.hill.yPos; tries++ {
+		xPos, yPos = g.mapData.randomOpenPoint(g.rng)
+	}
+
+	m.hill = &Hill{xPos: xPos, yPos: yPos, placedAt: g.now()}
+	g.sendInfo([]byte(fmt.Sprintf("HillMoved::%f/%f", xPos, yPos)))
+}
+
+// updateHill checks who stands inside of the hill, awards its sole owner and moves the hill once its time is up
+func (m *kingOfTheHill) updateHill(g *Game) {
+	if g.roundStart.IsZero() {
+		return
+	}
+	if m.hill == nil || g.now().Sub(m.hill.placedAt) >= hillMoveTime {
+		m.placeHill(g)
+	}
+
+	var owner *Player
+	contested := false
+	for _, player := range g.playersById() {
+		if !player.alive || math.Hypot(player.xPos-m.hill.xPos, player.yPos-m.hill.yPos) > hillRadius {
+			continue
+		}
+		if owner != nil {
+			contested = true
+			break
+		}
+		owner = player
+	}
+	if contested {
+		owner = nil
+	}
+
+	// Leaving the hill or losing it to a contest resets the progress towards the next point
+	if owner != m.hill.owner {
+		m.hill.progress = 0
+	}
+	m.hill.owner = owner
+	m.hill.contested = contested
+	if owner == nil {
+		return
+	}
+
+	m.hill.progress += timeFactor / hillPointTime.Seconds()
+	if m.hill.progress >= 1 {
+		m.hill.progress--
+		owner.score++
+		m.points[owner]++
+		g.sendInfo(g.getScoreBoardUpdate())
+	}
+}
+
+// checkRoundEnd reports whether a player has held the hill long enough or the time is up,
+// the player with the most hill points wins then and nil is returned on a draw
+func (m *kingOfTheHill) checkRoundEnd(g *Game) (*Player, bool) {
+	var leader *Player
+	tied := false
+	for _, player := range g.playersById() {
+		if m.points[player] >= hillPointLimit {
+			return player, true
+		}
+		if leader == nil || m.points[player] > m.points[leader] {
+			leader, tied = player, false
+		} else if m.points[player] == m.points[leader] {
+			tied = true
+		}
+	}
+
+	if g.roundStart.IsZero() || g.now().Sub(g.roundStart) < g.settings.timeLimit(hillTimeLimit) {
+		return nil, false
+	}
+	if tied {
+		return nil, true
+	}
+	return leader, true
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"projectparty/store"
+)
+
+// startHistory begins the history of the game once it starts, with the players it starts with.
+// The dates of the match are taken from the wall clock, the game clock only keeps the time within the game
+func (g *Game) startHistory() {
+	g.history = &store.Match{GameId: g.id, Seed: g.seed, Mode: g.modeName, Started: time.Now()}
+	for _, player := range g.playersById() {
+		participant := store.Participant{Id: player.id, Nick: player.nick, Team: player.team}
+		if player.profile != nil {
+			participant.ProfileId = player.profile.Id
+		}
+		g.history.Players = append(g.history.Players, participant)
+	}
+}
+
+// sendEndRound announces the end of a round along with its stats and adds its result to the history, winner being the id of a player
+// and team the winning team in the modes with teams, drawMarker standing for none
+//		EndRound::winner in the modes without teams
+//		EndRound::winner::team in the modes with teams
+func (g *Game) sendEndRound(winner, team int) {
+	if g.history != nil {
+		g.history.Rounds = append(g.history.Rounds, store.Round{
+			Number:   g.roundCount,
+			MapSeed:  g.mapSeed,
+			Winner:   winner,
+			Team:     team,
+			Duration: g.now().Sub(g.roundStart),
+		})
+	}
+
+	if g.teamCount > 0 {
+		g.sendInfo([]byte(fmt.Sprintf("EndRound::%d::%d", winner, team)))
+	} else {
+		g.sendInfo([]byte(fmt.Sprintf("EndRound::%d", winner)))
+	}
+	g.sendStats("round", g.roundStats())
+}
+
+// addKill adds a player shot by another one to the history
+func (g *Game) addKill(shooter, target *Player) {
+	if g.history != nil {
+		g.history.Kills = append(g.history.Kills, store.Kill{Round: g.roundCount, Tick: g.tick, Shooter: shooter.id, Target: target.id})
+	}
+}
+
+// saveHistory completes the history of the game with its final standings and the stats of the players and stores it
+func (g *Game) saveHistory(standings []Standing) {
+	if g.history == nil {
+		return
+	}
+	g.updateProfiles(standings)
+
+	g.history.Ended = time.Now()
+	stats := make(map[int]store.Stats, len(g.players))
+	for _, player := range g.players {
+		stats[player.id] = player.stats.game.Stats
+	}
+	for i := range g.history.Players {
+		g.history.Players[i].Stats = stats[g.history.Players[i].Id]
+	}
+	for _, standing := range standings {
+		ids := make([]int, 0, len(standing.players))
+		for _, player := range standing.players {
+			ids = append(ids, player.id)
+		}
+		g.history.Standings = append(g.history.Standings, store.Standing{Place: standing.place, Score: standing.score, Team: standing.team, Players: ids})
+	}
+
+	if err := storage.SaveMatch(g.history); err != nil {
+		fmt.Fprintf(g.logger, "Saving the history of game with id %d failed with error %s\n", g.id, err)
+		return
+	}
+	fmt.Fprintln(g.logger, "Game with id ", g.id, " saved as match ", g.history.Id)
+	g.updateRatings(g.history.Id, standings)
+}
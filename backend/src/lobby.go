@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GameState is a stage of the game flow, the game moves between them only along stateTransitions
+type GameState int
+
+const (
+	stateLobby      GameState = iota // Players are joining, the host sets the game up
+	stateCountdown                   // The host has started the game, the first round is about to begin
+	stateInRound                     // A round is being played
+	stateRoundBreak                  // A round has ended, the next one is about to begin
+	stateFinished                    // The game has ended
+)
+
+var stateNames = map[GameState]string{
+	stateLobby:      "lobby",
+	stateCountdown:  "countdown",
+	stateInRound:    "inRound",
+	stateRoundBreak: "roundBreak",
+	stateFinished:   "finished",
+}
+
+// stateTransitions lists the states every state can be followed by
+var stateTransitions = map[GameState][]GameState{
+	stateLobby:      {stateCountdown},
+	stateCountdown:  {stateInRound, stateLobby},
+	stateInRound:    {stateRoundBreak, stateFinished},
+	stateRoundBreak: {stateInRound, stateFinished},
+	stateFinished:   {},
+}
+
+func (s GameState) String() string {
+	return stateNames[s]
+}
+
+// setState moves the game to the next state and lets the host know, unless the transition isn't allowed
+func (g *Game) setState(next GameState) error {
+	allowed := false
+	for _, state := range stateTransitions[g.state] {
+		if state == next {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("game can't go from %s to %s", g.state, next)
+	}
+
+	fmt.Fprintln(g.logger, "Game with id ", g.id, " goes from ", g.state, " to ", next)
+	g.state = next
+	g.sendInfo([]byte(fmt.Sprintf("State::%s", next)))
+	return nil
+}
+
+// start processes the Start command sent by the host, params being "force" if not every player has to be ready
+func (g *Game) start(params string) error {
+	if g.state != stateLobby {
+		return errors.New("game has already started")
+	}
+	if len(g.players) < minPlayers {
+		return fmt.Errorf("at least %d players are needed to start", minPlayers)
+	}
+	if params != "force" {
+		for _, player := range g.players {
+			if !player.ready {
+				return fmt.Errorf("player %s is not ready", player.nick)
+			}
+		}
+	}
+
+	g.startRecording()
+	g.startHistory()
+	if err := g.setState(stateCountdown); err != nil {
+		return err
+	}
+	g.round()
+	return nil
+}
+
+// admitController checks whether a controller can join the game in its current state
+func (g *Game) admitController(controller *Controller) error {
+	switch {
+	case g.state == stateFinished:
+		return errors.New("game has already finished")
+	case g.state != stateLobby:
+		return errors.New("game has already started")
+	case len(g.players) >= maxPlayers:
+		return errors.New("game is full")
+	case !g.isNickAvailable(controller.nick):
+		return errors.New("nick is not available")
+	case controller.profileRequest != nil && controller.profileRequest.existing != nil && g.hasProfile(controller.profileRequest.existing.Id):
+		return errors.New("profile is already in the game")
+	}
+
+	return nil
+}
+
+// setReady processes the Ready command sent by a controller, params being "1" or "0"
+func (g *Game) setReady(player *Player, params string) {
+	if g.state != stateLobby {
+		return
+	}
+
+	player.ready = params == "1"
+	ready := 0
+	if player.ready {
+		ready = 1
+	}
+	g.sendInfo([]byte(fmt.Sprintf("Ready::%d/%d", player.id, ready)))
+}
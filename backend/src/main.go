@@ -5,12 +5,28 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
+	"sync"
 )
 
 func main() {
 	flag.Parse()
+	if flag.Arg(0) == "simulate" {
+		if err := runSimulation(flag.Args()[1:]); err != nil {
+			log.Fatal("simulate: ", err)
+		}
+		return
+	}
+	if err := openStorage(); err != nil {
+		log.Fatal("store: ", err)
+	}
+	defer storage.Close()
+
 	games := make([]*Game, 0)
+	// Games of the playbacks in progress are kept apart, as only screens can join them
+	replays := make(map[uint64]*Game)
+	var replaysLock sync.Mutex
 
 	http.HandleFunc("/screenWs", func(w http.ResponseWriter, r *http.Request) {
 		keys, ok := r.URL.Query()["id"]
@@ -26,6 +42,11 @@ func main() {
 		}
 
 		game := findGameById(gameId, games)
+		if game == nil {
+			replaysLock.Lock()
+			game = replays[gameId]
+			replaysLock.Unlock()
+		}
 
 		if game == nil {
 			return
@@ -38,7 +59,7 @@ func main() {
 	})
 
 	http.HandleFunc("/gameInfoWs", func(w http.ResponseWriter, r *http.Request) {
-		game, err := newGame()
+		game, err := newGame(os.Stdout)
 		if err != nil {
 			return
 		}
@@ -49,6 +70,30 @@ func main() {
 		serveGameInfoWs(game, w, r)
 	})
 
+	http.HandleFunc("/replayWs", func(w http.ResponseWriter, r *http.Request) {
+		// The playback gets a game of its own only for the screens to find it by its id, once the replay has been loaded
+		serveReplayWs(w, r, func() (*Game, error) {
+			game, err := newGame(os.Stdout)
+			if err != nil {
+				return nil, err
+			}
+
+			replaysLock.Lock()
+			replays[game.id] = game
+			replaysLock.Unlock()
+			fmt.Println("created replay with id ", game.id)
+			return game, nil
+		}, func(game *Game) {
+			replaysLock.Lock()
+			delete(replays, game.id)
+			replaysLock.Unlock()
+			fmt.Println("removed replay with id ", game.id)
+		})
+	})
+
+	http.HandleFunc("/metrics", serveMetrics)
+	http.HandleFunc("/ratings", serveRatings)
+
 	log.Println("app started")
 	err := http.ListenAndServe(*addr, nil)
 	if err != nil {
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	// "fmt"
 	"math"
+	"math/rand"
+
+	"projectparty/nav"
 )
 
 type Map struct {
@@ -14,6 +17,8 @@ type Map struct {
 		Y float64 `json:"y"`
 	} `json:"spawnPoints"`
 	ErrorInfo interface{} `json:"error"`
+
+	grid *nav.Grid // Walkable graph of the map, nil until it's needed
 }
 
 func createMapFromJson(jsonData []byte) Map {
@@ -120,4 +125,35 @@ func (m *Map) smoothCollison(wallAngle float64, newX float64, newY float64, dx f
 	newerY := -sinA*transformedX + dy
 
 	return newerX, newerY
-}
\ No newline at end of file
+}
+
+// wallCollision checks whether a circle with centre in (cx, cy) and radius r touches any of the walls
+func (m *Map) wallCollision(cx, cy, r float64) bool {
+	for _, wall := range m.Walls {
+		for i := 0; i < len(wall)-1; i += 2 {
+			if m.lineCircleCollision(wall[i], wall[i+1], wall[(i+2)%len(wall)], wall[(i+3)%len(wall)], cx, cy, r) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// randomOpenPoint returns the centre of a random cell where a player can stand, picked with rng,
+// the centre of the map is returned if there is no such cell
+func (m *Map) randomOpenPoint(rng *rand.Rand) (float64, float64) {
+	point, ok := m.navigation().RandomPoint(rng)
+	if !ok {
+		return 0.5, 0.5
+	}
+	return point.X, point.Y
+}
+
+// navigation returns the walkable graph of the map for the bodies of the players, built on first use
+func (m *Map) navigation() *nav.Grid {
+	if m.grid == nil {
+		m.grid = nav.ForMap(m.MapData, m.Walls, playerRadius)
+	}
+	return m.grid
+}
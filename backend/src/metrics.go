@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// Metrics counts what happens to the messages sent to the clients, shared by all of the games
+type Metrics struct {
+	FramesSent       uint64 `json:"framesSent"`       // Gameplay packets written to screens
+	FramesDropped    uint64 `json:"framesDropped"`    // Gameplay packets replaced by a newer one before a screen took them
+	EventsSent       uint64 `json:"eventsSent"`       // Event messages queued for any client
+	SlowDisconnected uint64 `json:"slowDisconnected"` // Clients disconnected for falling too far behind
+}
+
+var metrics Metrics
+
+// serveMetrics writes the current metrics as a JSON object
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	snapshot := Metrics{
+		FramesSent:       atomic.LoadUint64(&metrics.FramesSent),
+		FramesDropped:    atomic.LoadUint64(&metrics.FramesDropped),
+		EventsSent:       atomic.LoadUint64(&metrics.EventsSent),
+		SlowDisconnected: atomic.LoadUint64(&metrics.SlowDisconnected),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
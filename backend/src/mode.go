@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GameMode holds the rules of a game: how rounds start and end, who scores and who wins in the end.
+// The game loop only calls the hooks below, so adding a mode doesn't require touching it
+type GameMode interface {
+	// teams returns how many teams play in the mode (0 if none) and whether they can kill each other
+	teams() (int, bool)
+	// onRoundStart is called once the map of a new round has been loaded, before the players respawn
+	onRoundStart(g *Game)
+	// spawnIndex picks the spawn point for a player coming back to life
+	spawnIndex(g *Game, p *Player) int
+	// onTick is called after every step of the simulation
+	onTick(g *Game)
+	// onHit is called when a shot is about to kill the target, it should award the shooter
+	onHit(g *Game, shooter, target *Player)
+	// onDeath is called whenever a player dies, shot or not
+	onDeath(g *Game, victim *Player)
+	// isRoundOver checks whether the current round is over, it mustn't change the game
+	isRoundOver(g *Game) bool
+	// endRound is called once the round is over, it awards the winners and announces the result
+	endRound(g *Game)
+	// isGameOver checks whether the game should end instead of starting another round
+	isGameOver(g *Game) bool
+	// finalStandings returns the results of the game ordered from the first place, one entry per player or team
+	finalStandings(g *Game) []Standing
+	// objectives returns the mode specific part appended to the gameplay packet, including the leading separators
+	objectives(g *Game) string
+}
+
+// Standing is the result of a single player or a whole team at the end of a game
+type Standing struct {
+	place   int // Starting from 1, entries which tied share the place
+	score   int
+	team    int // noTeam unless the entry belongs to a team
+	players []*Player
+}
+
+// gameModes creates the game modes the host can choose from, params being the rest of the Mode command split by "/"
+var gameModes = map[string]func(params []string) (GameMode, error){
+	modeFreeForAll: func(params []string) (GameMode, error) {
+		return &lastManStanding{}, nil
+	},
+	modeTeams:          newTeamDeathmatch,
+	modeCaptureTheFlag: newCaptureTheFlag,
+	modeKingOfTheHill: func(params []string) (GameMode, error) {
+		return newKingOfTheHill(), nil
+	},
+	modeDeathmatch: func(params []string) (GameMode, error) {
+		return &deathmatch{}, nil
+	},
+}
+
+// setMode processes the Mode command sent by the host,
+// params are either "ffa", "teams/$teamCount/$friendlyFire", "ctf/$friendlyFire", "koth" or "dm"
+func (g *Game) setMode(params string) error {
+	if g.state != stateLobby {
+		return errors.New("mode can only be changed before the game starts")
+	}
+
+	parts := strings.Split(params, "/")
+	create, ok := gameModes[parts[0]]
+	if !ok {
+		return fmt.Errorf("unknown mode %s", parts[0])
+	}
+	mode, err := create(parts[1:])
+	if err != nil {
+		return err
+	}
+
+	g.mode = mode
+	g.modeName = params
+	// Only the modes with teams come with a friendly fire flag, the others keep the one the host set in the settings
+	teamCount, friendlyFire := mode.teams()
+	g.teamCount = teamCount
+	if teamCount > 0 {
+		g.settings.FriendlyFire = friendlyFire
+	}
+	g.teamScores = make([]int, g.teamCount)
+	g.teamRoundsWon = make([]int, g.teamCount)
+	g.assignTeams()
+	return nil
+}
+
+// respawnDead brings back the players who have waited long enough since their death, protecting them for a while
+func (g *Game) respawnDead() {
+	if g.roundStart.IsZero() {
+		return
+	}
+
+	for _, player := range g.playersById() {
+		if player.alive || player.diedAt.IsZero() || g.now().Sub(player.diedAt) < respawnDelay {
+			continue
+		}
+		player.respawn()
+		player.protectedUntil = g.now().Add(spawnProtection)
+	}
+}
+
+// announceRoundWinner awards the winner of a round played by individual players and announces him, nil being a draw
+func (g *Game) announceRoundWinner(victor *Player, prize int) {
+	if victor == nil {
+		fmt.Fprintln(g.logger, "Sending info about end of round with a draw")
+		g.sendEndRound(drawMarker, drawMarker)
+		return
+	}
+
+	fmt.Fprintln(g.logger, "Sending info about end of round with victor with id ", victor.id)
+	victor.score += prize
+	victor.roundsWon++
+	g.sendInfo(g.getScoreBoardUpdate())
+	g.sendEndRound(victor.id, drawMarker)
+}
+
+// individualStandings orders the players by score, players sharing a score are told apart by rounds won
+// and then by kills, if that still doesn't settle it they share the place
+func (g *Game) individualStandings() []Standing {
+	players := g.playersById()
+	sort.SliceStable(players, func(i, j int) bool {
+		return compareStanding(players[i], players[j]) > 0
+	})
+
+	result := make([]Standing, 0, len(players))
+	for i, player := range players {
+		place := i + 1
+		if i > 0 && compareStanding(players[i-1], player) == 0 {
+			place = result[i-1].place
+		}
+		result = append(result, Standing{place, player.score, noTeam, []*Player{player}})
+	}
+
+	return result
+}
+
+// compareStanding(a, b *Player) returns a positive number if a placed better than b, negative if worse and 0 on a tie
+func compareStanding(a, b *Player) int {
+	if a.score != b.score {
+		return a.score - b.score
+	}
+	if a.roundsWon != b.roundsWon {
+		return a.roundsWon - b.roundsWon
+	}
+	return a.kills - b.kills
+}
+
+// lastManStanding is the default mode: every player for himself, the last one alive wins the round
+// and sudden death starts if the round takes too long
+type lastManStanding struct {
+	zone *SafeZone // The sudden death zone, nil until the round time limit passes
+}
+
+func (m *lastManStanding) teams() (int, bool) {
+	return 0, false
+}
+
+func (m *lastManStanding) onRoundStart(g *Game) {
+	m.zone = nil
+}
+
+func (m *lastManStanding) spawnIndex(g *Game, p *Player) int {
+	return g.spawns.index(g, p)
+}
+
+func (m *lastManStanding) onTick(g *Game) {
+	m.updateZone(g)
+}
+
+func (m *lastManStanding) onHit(g *Game, shooter, target *Player) {
+	shooter.score++
+	shooter.kills++
+}
+
+func (m *lastManStanding) onDeath(g *Game, victim *Player) {}
+
+// isRoundOver ends the round once at most one player is left alive
+func (m *lastManStanding) isRoundOver(g *Game) bool {
+	_, over := m.checkRoundEnd(g)
+	return over
+}
+
+// endRound awards the last player standing, nobody surviving makes it a draw
+func (m *lastManStanding) endRound(g *Game) {
+	victor, _ := m.checkRoundEnd(g)
+	g.announceRoundWinner(victor, g.settings.Prize)
+}
+
+// checkRoundEnd reports whether at most one player is left alive and returns him, nil if nobody survived
+func (m *lastManStanding) checkRoundEnd(g *Game) (*Player, bool) {
+	var victorAlive *Player = nil
+	for _, currPlayer := range g.playersById() {
+		if currPlayer.alive && victorAlive == nil {
+			victorAlive = currPlayer
+		} else if currPlayer.alive && victorAlive != nil {
+			return nil, false
+		}
+	}
+
+	return victorAlive, true
+}
+
+func (m *lastManStanding) isGameOver(g *Game) bool {
+	return g.roundCount >= g.settings.Rounds
+}
+
+func (m *lastManStanding) finalStandings(g *Game) []Standing {
+	return g.individualStandings()
+}
+
+// objectives sends the sudden death zone once it appears
+func (m *lastManStanding) objectives(g *Game) string {
+	if m.zone == nil {
+		return ""
+	}
+	return ":" + m.zone.String()
+}
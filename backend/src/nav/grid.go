@@ -0,0 +1,194 @@
+// Package nav answers the questions about moving around a map: where a player fits, how to get from one place
+// to another and what can be seen from where. It works on the cells of the maps sent by the map service
+// and is shared by every game, so the graph of a map is built only once.
+package nav
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// maxCachedGrids is how many grids are kept around for reuse, enough for every round of the games played at once
+const maxCachedGrids = 32
+
+// Point is a position in game coordinates, [0, 1] on both axes
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Grid is the walkable graph of a map: its cells, with the ones a player's body can't stand in
+// (too close to a wall) left out, and its walls for line of sight checks. A Grid is never changed once built,
+// so it can be used by several goroutines at once
+type Grid struct {
+	rows     int
+	cols     int
+	walkable []bool // Indexed by row*cols + col
+	walls    [][]float64
+}
+
+// cache holds the grids built so far by the fingerprint of their map, oldest first in order
+var cache = struct {
+	sync.Mutex
+	grids map[uint64]*Grid
+	order []uint64
+}{grids: make(map[uint64]*Grid)}
+
+// ForMap returns the grid of a map for bodies of the given radius, building it unless the same map has been seen before.
+// cells are indexed by [row][col], 0 being an open cell, and walls are closed polygons in the format of the map service
+func ForMap(cells [][]int, walls [][]float64, radius float64) *Grid {
+	key := fingerprint(cells, walls, radius)
+	cache.Lock()
+	grid, ok := cache.grids[key]
+	cache.Unlock()
+	if ok {
+		return grid
+	}
+
+	grid = build(cells, walls, radius)
+	cache.Lock()
+	defer cache.Unlock()
+	if _, ok := cache.grids[key]; !ok {
+		cache.grids[key] = grid
+		cache.order = append(cache.order, key)
+		if len(cache.order) > maxCachedGrids {
+			delete(cache.grids, cache.order[0])
+			cache.order = cache.order[1:]
+		}
+	}
+	return grid
+}
+
+// fingerprint tells maps apart without keeping them around
+func fingerprint(cells [][]int, walls [][]float64, radius float64) uint64 {
+	hash := fnv.New64a()
+	buffer := make([]byte, 8)
+	write := func(value uint64) {
+		for i := range buffer {
+			buffer[i] = byte(value >> (8 * uint(i)))
+		}
+		hash.Write(buffer)
+	}
+
+	write(math.Float64bits(radius))
+	write(uint64(len(cells)))
+	for _, row := range cells {
+		write(uint64(len(row)))
+		for _, value := range row {
+			write(uint64(value))
+		}
+	}
+	write(uint64(len(walls)))
+	for _, wall := range walls {
+		write(uint64(len(wall)))
+		for _, value := range wall {
+			write(math.Float64bits(value))
+		}
+	}
+
+	return hash.Sum64()
+}
+
+// build marks the cells whose centre a body of the given radius can stand on: the cell has to be open,
+// so do all the cells the body reaches into, and the body must not touch any of the walls
+func build(cells [][]int, walls [][]float64, radius float64) *Grid {
+	g := &Grid{rows: len(cells), walls: walls}
+	for _, row := range cells {
+		if len(row) > g.cols {
+			g.cols = len(row)
+		}
+	}
+	g.walkable = make([]bool, g.rows*g.cols)
+	if g.rows == 0 || g.cols == 0 {
+		return g
+	}
+
+	open := func(row, col int) bool {
+		return row >= 0 && row < len(cells) && col >= 0 && col < len(cells[row]) && cells[row][col] == 0
+	}
+	// The body reaches at least into the neighbouring cells, more of them if it's bigger than a cell
+	reachX := int(math.Max(1, math.Ceil(radius*float64(g.cols))))
+	reachY := int(math.Max(1, math.Ceil(radius*float64(g.rows))))
+	for row := 0; row < g.rows; row++ {
+		for col := 0; col < g.cols; col++ {
+			g.walkable[row*g.cols+col] = g.fits(open, row, col, reachX, reachY, radius)
+		}
+	}
+
+	return g
+}
+
+// fits checks whether a body of the given radius can stand at the centre of the cell
+func (g *Grid) fits(open func(row, col int) bool, row, col, reachX, reachY int, radius float64) bool {
+	for r := row - reachY; r <= row+reachY; r++ {
+		for c := col - reachX; c <= col+reachX; c++ {
+			if !open(r, c) {
+				return false
+			}
+		}
+	}
+
+	centre := g.centre(row, col)
+	for _, wall := range g.walls {
+		for i := 0; i < len(wall)-1; i += 2 {
+			if segmentDistance(centre, Point{wall[i], wall[i+1]}, Point{wall[(i+2)%len(wall)], wall[(i+3)%len(wall)]}) < radius {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Walkable checks whether a body the grid was built for can stand at the given point, judged by the cell it lies in
+func (g *Grid) Walkable(x, y float64) bool {
+	row, col := g.cellAt(x, y)
+	return g.walkableCell(row, col)
+}
+
+// RandomPoint returns the centre of a random walkable cell picked with rng, false if there is none
+func (g *Grid) RandomPoint(rng *rand.Rand) (Point, bool) {
+	cells := make([]int, 0)
+	for i, walkable := range g.walkable {
+		if walkable {
+			cells = append(cells, i)
+		}
+	}
+	if len(cells) == 0 {
+		return Point{}, false
+	}
+
+	picked := cells[rng.Intn(len(cells))]
+	return g.centre(picked/g.cols, picked%g.cols), true
+}
+
+func (g *Grid) walkableCell(row, col int) bool {
+	return row >= 0 && row < g.rows && col >= 0 && col < g.cols && g.walkable[row*g.cols+col]
+}
+
+// cellAt returns the cell containing the point, which may lie outside of the grid
+func (g *Grid) cellAt(x, y float64) (int, int) {
+	return int(math.Floor(y * float64(g.rows))), int(math.Floor(x * float64(g.cols)))
+}
+
+// centre converts cell indices into game coordinates, the same way the map service places spawn points
+func (g *Grid) centre(row, col int) Point {
+	return Point{(float64(col) + 0.5) / float64(g.cols), (float64(row) + 0.5) / float64(g.rows)}
+}
+
+// nearestWalkable returns the walkable cell closest to the given one, searching at most maxDistance cells away
+func (g *Grid) nearestWalkable(row, col, maxDistance int) (int, int, bool) {
+	for distance := 0; distance <= maxDistance; distance++ {
+		for r := row - distance; r <= row+distance; r++ {
+			for c := col - distance; c <= col+distance; c++ {
+				if g.walkableCell(r, c) {
+					return r, c, true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
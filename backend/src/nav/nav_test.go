@@ -0,0 +1,161 @@
+package nav
+
+import (
+	"math"
+	"testing"
+)
+
+// parseCells turns rows of '.' (open) and '#' (filled) into cells in the format of the map service
+func parseCells(rows ...string) [][]int {
+	cells := make([][]int, len(rows))
+	for r, row := range rows {
+		cells[r] = make([]int, len(row))
+		for c, cell := range row {
+			if cell == '#' {
+				cells[r][c] = 1
+			}
+		}
+	}
+	return cells
+}
+
+// centreOf returns the game coordinates of the centre of a cell of the grid
+func centreOf(g *Grid, row, col int) (float64, float64) {
+	centre := g.centre(row, col)
+	return centre.X, centre.Y
+}
+
+// detour has a pillar in the middle which bodies can only walk around from below
+var detour = parseCells(
+	"...........",
+	"...........",
+	".....#.....",
+	".....#.....",
+	"...........",
+	"...........",
+	"...........",
+)
+
+// pockets is split in two by a pillar which leaves no room to pass on either side
+var pockets = parseCells(
+	"...........",
+	"...........",
+	".....#.....",
+	"...........",
+	"...........",
+)
+
+const radius = 0.01 // Smaller than a cell, so a body reaches only into the neighbouring cells
+
+func TestDistances(t *testing.T) {
+	detourGrid := ForMap(detour, nil, radius)
+	pocketsGrid := ForMap(pockets, nil, radius)
+	dx, dy := 1.0/11, 1.0/7
+
+	tests := []struct {
+		name     string
+		grid     *Grid
+		from, to [2]int // Cells as row, col
+		want     float64
+	}{
+		{"same cell", detourGrid, [2]int{3, 3}, [2]int{3, 3}, 0},
+		{"straight", detourGrid, [2]int{3, 1}, [2]int{3, 3}, 2 * dx},
+		{"diagonal", detourGrid, [2]int{1, 1}, [2]int{2, 2}, math.Hypot(dx, dy)},
+		{"around the pillar", detourGrid, [2]int{3, 3}, [2]int{3, 7}, 4*dx + 4*dy},
+		{"other pocket", pocketsGrid, [2]int{2, 2}, [2]int{2, 8}, math.Inf(1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fromX, fromY := centreOf(test.grid, test.from[0], test.from[1])
+			toX, toY := centreOf(test.grid, test.to[0], test.to[1])
+			got := test.grid.Distances(fromX, fromY).To(toX, toY)
+			if math.IsInf(test.want, 1) != math.IsInf(got, 1) || (!math.IsInf(got, 1) && math.Abs(got-test.want) > 1e-9) {
+				t.Errorf("distance = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	detourGrid := ForMap(detour, nil, radius)
+	pocketsGrid := ForMap(pockets, nil, radius)
+	dx, dy := 1.0/11, 1.0/7
+
+	tests := []struct {
+		name     string
+		grid     *Grid
+		from, to [2]int
+		found    bool
+		length   float64
+	}{
+		{"same cell", detourGrid, [2]int{3, 3}, [2]int{3, 3}, true, 0},
+		{"straight", detourGrid, [2]int{5, 1}, [2]int{5, 9}, true, 8 * dx},
+		{"around the pillar", detourGrid, [2]int{3, 3}, [2]int{3, 7}, true, 4*dx + 4*dy},
+		{"other pocket", pocketsGrid, [2]int{2, 2}, [2]int{2, 8}, false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fromX, fromY := centreOf(test.grid, test.from[0], test.from[1])
+			toX, toY := centreOf(test.grid, test.to[0], test.to[1])
+			path := test.grid.Path(fromX, fromY, toX, toY)
+			if !test.found {
+				if path != nil {
+					t.Errorf("path = %v, want none", path)
+				}
+				return
+			}
+			if path == nil {
+				t.Fatal("no path found")
+			}
+
+			length := 0.0
+			previous := Point{fromX, fromY}
+			for _, point := range path {
+				if !test.grid.Walkable(point.X, point.Y) {
+					t.Errorf("path goes through %v, which isn't walkable", point)
+				}
+				length += math.Hypot(point.X-previous.X, point.Y-previous.Y)
+				previous = point
+			}
+			if previous.X != toX || previous.Y != toY {
+				t.Errorf("path ends at %v, want (%v, %v)", previous, toX, toY)
+			}
+			if math.Abs(length-test.length) > 1e-9 {
+				t.Errorf("path length = %v, want %v", length, test.length)
+			}
+		})
+	}
+}
+
+func TestLineOfSight(t *testing.T) {
+	// A single wall standing upright in the middle of the map
+	grid := ForMap(parseCells("....", "....", "....", "...."), [][]float64{{0.5, 0.2, 0.5, 0.8}}, radius)
+
+	tests := []struct {
+		name   string
+		a, b   Point
+		expect bool
+	}{
+		{"across the wall", Point{0.2, 0.5}, Point{0.8, 0.5}, false},
+		{"past the wall", Point{0.2, 0.1}, Point{0.8, 0.1}, true},
+		{"short of the wall", Point{0.2, 0.5}, Point{0.4, 0.5}, true},
+		{"touching its end", Point{0.2, 0.2}, Point{0.8, 0.2}, false},
+		{"along the wall", Point{0.5, 0.85}, Point{0.5, 0.95}, true},
+		{"through the wall lengthwise", Point{0.5, 0.1}, Point{0.5, 0.9}, false},
+		{"into the wall lengthwise", Point{0.5, 0.5}, Point{0.5, 0.95}, false},
+		{"diagonally across", Point{0.1, 0.1}, Point{0.9, 0.9}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := grid.LineOfSight(test.a.X, test.a.Y, test.b.X, test.b.Y); got != test.expect {
+				t.Errorf("LineOfSight(%v, %v) = %v, want %v", test.a, test.b, got, test.expect)
+			}
+			if got := grid.LineOfSight(test.b.X, test.b.Y, test.a.X, test.a.Y); got != test.expect {
+				t.Errorf("LineOfSight(%v, %v) = %v, want %v", test.b, test.a, got, test.expect)
+			}
+		})
+	}
+}
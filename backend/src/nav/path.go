@@ -0,0 +1,152 @@
+package nav
+
+import (
+	"container/heap"
+	"math"
+)
+
+// maxSnapDistance is how many cells away from a point its nearest walkable cell is looked for,
+// points in a wall or in a narrow passage are snapped onto the graph that way
+const maxSnapDistance = 3
+
+// steps are the moves between neighbouring cells, diagonal ones included
+var steps = [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+
+// Path searches for the shortest path with A* from one point to another, returning the centres of the cells to walk through,
+// the last one being the cell of the goal. nil is returned if either point is too far from the graph or there is no path
+func (g *Grid) Path(fromX, fromY, toX, toY float64) []Point {
+	start, ok := g.snap(fromX, fromY)
+	if !ok {
+		return nil
+	}
+	goal, ok := g.snap(toX, toY)
+	if !ok {
+		return nil
+	}
+
+	goalCentre := g.centre(goal/g.cols, goal%g.cols)
+	estimate := func(cell int) float64 {
+		centre := g.centre(cell/g.cols, cell%g.cols)
+		return math.Hypot(centre.X-goalCentre.X, centre.Y-goalCentre.Y)
+	}
+
+	cost := map[int]float64{start: 0}
+	previous := make(map[int]int)
+	open := &cellQueue{{start, estimate(start)}}
+	for open.Len() > 0 {
+		current := heap.Pop(open).(queuedCell)
+		if current.cell == goal {
+			break
+		}
+		g.neighbours(current.cell, func(next int, length float64) {
+			nextCost := cost[current.cell] + length
+			if known, ok := cost[next]; ok && known <= nextCost {
+				return
+			}
+			cost[next] = nextCost
+			previous[next] = current.cell
+			heap.Push(open, queuedCell{next, nextCost + estimate(next)})
+		})
+	}
+
+	if _, ok := cost[goal]; !ok {
+		return nil
+	}
+	path := make([]Point, 0)
+	for current := goal; current != start; current = previous[current] {
+		path = append(path, g.centre(current/g.cols, current%g.cols))
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path
+}
+
+// Field holds the length of the shortest path from a single point to every walkable cell
+type Field struct {
+	grid     *Grid
+	distance []float64 // Indexed like Grid.walkable, +Inf for unreachable cells
+}
+
+// Distances computes the lengths of the shortest paths from the given point to every walkable cell at once,
+// which is cheaper than searching for many paths from the same point one by one
+func (g *Grid) Distances(x, y float64) *Field {
+	f := &Field{grid: g, distance: make([]float64, len(g.walkable))}
+	for i := range f.distance {
+		f.distance[i] = math.Inf(1)
+	}
+	start, ok := g.snap(x, y)
+	if !ok {
+		return f
+	}
+
+	f.distance[start] = 0
+	open := &cellQueue{{start, 0}}
+	for open.Len() > 0 {
+		current := heap.Pop(open).(queuedCell)
+		if current.priority > f.distance[current.cell] {
+			continue // Already reached by a shorter path
+		}
+		g.neighbours(current.cell, func(next int, length float64) {
+			if distance := current.priority + length; distance < f.distance[next] {
+				f.distance[next] = distance
+				heap.Push(open, queuedCell{next, distance})
+			}
+		})
+	}
+
+	return f
+}
+
+// To returns the length of the shortest path to the given point, +Inf if it can't be reached
+func (f *Field) To(x, y float64) float64 {
+	cell, ok := f.grid.snap(x, y)
+	if !ok {
+		return math.Inf(1)
+	}
+	return f.distance[cell]
+}
+
+// snap returns the index of the walkable cell nearest to the point
+func (g *Grid) snap(x, y float64) (int, bool) {
+	row, col := g.cellAt(x, y)
+	row, col, ok := g.nearestWalkable(row, col, maxSnapDistance)
+	return row*g.cols + col, ok
+}
+
+// neighbours calls visit with every walkable neighbour of the cell and the distance to it,
+// a diagonal step is only allowed if it doesn't cut the corner of a cell which isn't walkable
+func (g *Grid) neighbours(cell int, visit func(next int, length float64)) {
+	row, col := cell/g.cols, cell%g.cols
+	for _, step := range steps {
+		nextRow, nextCol := row+step[0], col+step[1]
+		if !g.walkableCell(nextRow, nextCol) {
+			continue
+		}
+		if step[0] != 0 && step[1] != 0 && (!g.walkableCell(row+step[0], col) || !g.walkableCell(row, col+step[1])) {
+			continue
+		}
+		visit(nextRow*g.cols+nextCol, math.Hypot(float64(step[1])/float64(g.cols), float64(step[0])/float64(g.rows)))
+	}
+}
+
+// queuedCell is a cell waiting in the open set with its priority, the lower the sooner it is visited
+type queuedCell struct {
+	cell     int
+	priority float64
+}
+
+// cellQueue is the open set of the searches, a min-heap of cells by priority
+type cellQueue []queuedCell
+
+func (q cellQueue) Len() int            { return len(q) }
+func (q cellQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q cellQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *cellQueue) Push(x interface{}) { *q = append(*q, x.(queuedCell)) }
+func (q *cellQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
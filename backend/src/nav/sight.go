@@ -0,0 +1,48 @@
+package nav
+
+import "math"
+
+// LineOfSight checks whether the segment between the two points doesn't cross any of the walls
+func (g *Grid) LineOfSight(ax, ay, bx, by float64) bool {
+	a, b := Point{ax, ay}, Point{bx, by}
+	for _, wall := range g.walls {
+		for i := 0; i < len(wall)-1; i += 2 {
+			if segmentsIntersect(a, b, Point{wall[i], wall[i+1]}, Point{wall[(i+2)%len(wall)], wall[(i+3)%len(wall)]}) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// segmentsIntersect checks whether the segments [a, b] and [c, d] have a common point
+func segmentsIntersect(a, b, c, d Point) bool {
+	denominator := (a.X-b.X)*(c.Y-d.Y) - (a.Y-b.Y)*(c.X-d.X)
+	if denominator == 0 {
+		if (b.X-a.X)*(c.Y-a.Y)-(b.Y-a.Y)*(c.X-a.X) != 0 {
+			return false // Parallel segments, looking past a wall doesn't block the view
+		}
+		// Collinear segments, which only meet if one of them reaches into the other
+		return onSegment(c, a, b) || onSegment(d, a, b) || onSegment(a, c, d) || onSegment(b, c, d)
+	}
+
+	t := ((a.X-c.X)*(c.Y-d.Y) - (a.Y-c.Y)*(c.X-d.X)) / denominator
+	u := -((a.X-b.X)*(a.Y-c.Y) - (a.Y-b.Y)*(a.X-c.X)) / denominator
+	return t >= 0 && t <= 1 && u >= 0 && u <= 1
+}
+
+// onSegment checks whether the point p, which lies on the line through a and b, lies between them
+func onSegment(p, a, b Point) bool {
+	return p.X >= math.Min(a.X, b.X) && p.X <= math.Max(a.X, b.X) && p.Y >= math.Min(a.Y, b.Y) && p.Y <= math.Max(a.Y, b.Y)
+}
+
+// segmentDistance returns the distance from the point p to the closest point of the segment [a, b]
+func segmentDistance(p, a, b Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/length))
+	}
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
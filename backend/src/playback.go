@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Playback plays a recorded replay back in place of a live game: the game info messages go to its own connection,
+// which takes the place of the game info socket, and the gameplay packets go to the screens of its game,
+// so a frontend can watch a past game the same way it watches a live one
+type Playback struct {
+	game *Game // Game holding the id and the screens of the playback, it's never run
+
+	conn *websocket.Conn
+
+	input    chan []byte
+	commands chan string
+
+	header  ReplayHeader
+	records []ReplayRecord // Ordered by tick
+	kills   []int          // Indexes of the kill records
+
+	cursor   int     // Index of the next record to play
+	tick     float64 // Current position of the playback
+	speed    float64 // Ticks played per tick of real time
+	paused   bool
+	lastKill int // Index into kills of the last kill jumped to, -1 if none
+}
+
+// loadReplay reads the records of a replay, a replay cut short by a crash is read up to its last complete record
+func loadReplay(name string) (ReplayHeader, []ReplayRecord, error) {
+	var header ReplayHeader
+	if name == "" || filepath.Base(name) != name {
+		return header, nil, errors.New("file must be the name of a replay")
+	}
+	file, err := os.Open(filepath.Join(*replayDir, name))
+	if err != nil {
+		return header, nil, errors.New("replay doesn't exist")
+	}
+	defer file.Close()
+
+	records := make([]ReplayRecord, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxReplayLine)
+	for scanner.Scan() {
+		var record ReplayRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+
+	if len(records) == 0 || records[0].Kind != "header" || json.Unmarshal(records[0].Data, &header) != nil {
+		return header, nil, errors.New("replay has no header")
+	}
+	if header.Version != replayVersion {
+		return header, nil, fmt.Errorf("replay version %d isn't supported", header.Version)
+	}
+
+	// Records are written in the order of their ticks, sorting only guards against replays edited by hand
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Tick < records[j].Tick })
+	return header, records, nil
+}
+
+// start sends the host what he would have received before the recorded game started and waits for the Start command
+func (p *Playback) start() {
+	p.send([]byte(fmt.Sprintf("NewGame::%d::%d", p.game.id, p.header.Seed)))
+	p.send([]byte("Mode::" + p.header.Mode))
+	settings, _ := json.Marshal(p.header.Settings)
+	p.send(append([]byte("Settings::"), settings...))
+
+	kills := make([]string, 0, len(p.kills))
+	for _, i := range p.kills {
+		record := p.records[i]
+		var kill ReplayKill
+		json.Unmarshal(record.Data, &kill)
+		kills = append(kills, fmt.Sprintf("%d/%d/%d", record.Tick, kill.Shooter, kill.Target))
+	}
+	for _, record := range p.records {
+		if record.Kind != "roster" {
+			continue
+		}
+		var roster []ReplayPlayer
+		json.Unmarshal(record.Data, &roster)
+		for _, player := range roster {
+			p.send([]byte(fmt.Sprintf("NewPlayer::%d/%s/%d", player.Id, player.Nick, player.Team)))
+		}
+	}
+
+	p.send([]byte(fmt.Sprintf("Replay::%d/%d::%s", p.records[0].Tick, p.records[len(p.records)-1].Tick, strings.Join(kills, ","))))
+	p.tick = float64(p.records[0].Tick)
+}
+
+// run plays the replay at its speed until the connection closes
+func (p *Playback) run() {
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case command, ok := <-p.commands:
+			if !ok {
+				return
+			}
+			p.processCommand(command)
+		case <-ticker.C:
+			if p.paused {
+				continue
+			}
+			p.tick += p.speed
+			p.playUntil(uint64(p.tick))
+		}
+	}
+}
+
+// playUntil plays every record up to the given tick, pausing at the end of the replay
+func (p *Playback) playUntil(tick uint64) {
+	for ; p.cursor < len(p.records) && p.records[p.cursor].Tick <= tick; p.cursor++ {
+		record := p.records[p.cursor]
+		var message string
+		switch record.Kind {
+		case "snapshot":
+			json.Unmarshal(record.Data, &message)
+			p.game.screens.broadcast([]byte(message))
+		case "event":
+			json.Unmarshal(record.Data, &message)
+			p.send([]byte(message))
+		}
+	}
+
+	if p.cursor == len(p.records) {
+		p.paused = true
+		p.send([]byte("ReplayEnded::"))
+	}
+}
+
+// seek moves the playback to the given tick and resends the last map and scoreboard before it,
+// so that the frontend shows the right round
+func (p *Playback) seek(tick uint64) {
+	p.cursor = sort.Search(len(p.records), func(i int) bool { return p.records[i].Tick >= tick })
+	p.tick = float64(tick)
+
+	var newRound, scoreboard string
+	for _, record := range p.records[:p.cursor] {
+		var message string
+		if record.Kind != "event" || json.Unmarshal(record.Data, &message) != nil {
+			continue
+		}
+		if strings.HasPrefix(message, "NewRound::") {
+			newRound = message
+		} else if strings.HasPrefix(message, "ScoreboardUpdate::") {
+			scoreboard = message
+		}
+	}
+	if newRound != "" {
+		p.send([]byte(newRound))
+	}
+	if scoreboard != "" {
+		p.send([]byte(scoreboard))
+	}
+}
+
+// killCam jumps to shortly before the kill with the given index into kills
+func (p *Playback) killCam(index int) error {
+	if index < 0 || index >= len(p.kills) {
+		return errors.New("no such kill")
+	}
+
+	p.lastKill = index
+	record := p.records[p.kills[index]]
+	var kill ReplayKill
+	json.Unmarshal(record.Data, &kill)
+
+	lead := uint64(killCamLead / refresh)
+	tick := p.records[0].Tick
+	if record.Tick > tick+lead {
+		tick = record.Tick - lead
+	}
+	p.seek(tick)
+	p.send([]byte(fmt.Sprintf("KillCam::%d/%d/%d", record.Tick, kill.Shooter, kill.Target)))
+	return nil
+}
+
+// processCommand processes the commands sent over the replay socket in the format "${command}::${params}"
+// Start, Resume - play the replay, Start being sent by frontends once they are ready to watch
+// Pause - stop the replay where it is
+// Seek - tick to continue from
+// Speed - how many times faster than real time the replay is played
+// KillCam - "next" or the index of a kill to jump to shortly before it
+func (p *Playback) processCommand(command string) {
+	parts := strings.SplitN(command, "::", 2)
+	if len(parts) != 2 {
+		p.send([]byte("Error::wrong message format"))
+		return
+	}
+
+	var err error
+	switch parts[0] {
+	case "Start", "Resume":
+		p.paused = false
+	case "Pause":
+		p.paused = true
+	case "Seek":
+		var tick uint64
+		if tick, err = strconv.ParseUint(parts[1], 10, 64); err == nil {
+			p.seek(tick)
+		}
+	case "Speed":
+		var speed float64
+		speed, err = strconv.ParseFloat(parts[1], 64)
+		if err == nil && (speed < minReplaySpeed || speed > maxReplaySpeed) {
+			err = fmt.Errorf("speed must be between %.2f and %.0f", minReplaySpeed, maxReplaySpeed)
+		}
+		if err == nil {
+			p.speed = speed
+		}
+	case "KillCam":
+		index := p.lastKill + 1
+		if parts[1] != "next" {
+			index, err = strconv.Atoi(parts[1])
+		}
+		if err == nil {
+			err = p.killCam(index)
+		}
+	default:
+		err = errors.New("unknown command " + parts[0])
+	}
+
+	if err != nil {
+		p.send([]byte("Error::" + err.Error()))
+	}
+}
+
+// send queues a message for the replay socket, disconnecting it if it falls too far behind
+func (p *Playback) send(message []byte) {
+	select {
+	case p.input <- message:
+	default:
+		log.Printf("disconnecting the replay of game %d, it doesn't keep up", p.game.id)
+		p.conn.Close()
+	}
+}
+
+// readPump pumps the commands from the websocket connection to the playback.
+//
+// The application runs readPump in a per-connection goroutine. The application
+// ensures that there is at most one reader on a connection by executing all
+// reads from this goroutine.
+func (p *Playback) readPump() {
+	defer func() {
+		close(p.commands)
+		p.conn.Close()
+	}()
+	p.conn.SetReadLimit(maxMessageSize)
+	p.conn.SetReadDeadline(time.Now().Add(pongWait))
+	p.conn.SetPongHandler(func(string) error { p.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	for {
+		_, message, err := p.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("error: %v", err)
+			}
+			break
+		}
+		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
+		p.commands <- string(message)
+	}
+}
+
+// writePump pumps messages from the playback to the websocket connection.
+//
+// A goroutine running writePump is started for each connection. The
+// application ensures that there is at most one writer to a connection by
+// executing all writes from this goroutine.
+func (p *Playback) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		p.conn.Close()
+	}()
+	for {
+		select {
+		case message := <-p.input:
+			p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := p.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := p.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// serveReplayWs plays the requested replay back over the connection, register creating the game of the playback
+// only once the replay has been loaded so that no game is left behind for a replay which can't be played,
+// unregister removes it again once the connection is closed
+func serveReplayWs(w http.ResponseWriter, r *http.Request, register func() (*Game, error), unregister func(*Game)) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	header, records, err := loadReplay(r.URL.Query().Get("file"))
+	if err != nil {
+		conn.WriteMessage(websocket.TextMessage, []byte("Error::"+err.Error()))
+		conn.WriteMessage(websocket.CloseMessage, []byte{})
+		return
+	}
+	game, err := register()
+	if err != nil {
+		conn.WriteMessage(websocket.TextMessage, []byte("Error::"+err.Error()))
+		conn.WriteMessage(websocket.CloseMessage, []byte{})
+		return
+	}
+
+	playback := &Playback{
+		game:     game,
+		conn:     conn,
+		input:    make(chan []byte, maxQueuedMessages),
+		commands: make(chan string),
+		header:   header,
+		records:  records,
+		speed:    1,
+		paused:   true,
+		lastKill: -1,
+	}
+	for i, record := range records {
+		if record.Kind == "kill" {
+			playback.kills = append(playback.kills, i)
+		}
+	}
+	playback.start()
+
+	// Allow collection of memory referenced by the caller by doing all work in
+	// new goroutines.
+	go game.screens.Run()
+	go playback.writePump()
+	go playback.readPump()
+	go func() {
+		playback.run()
+		unregister(game)
+	}()
+}
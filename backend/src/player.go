@@ -1,22 +1,36 @@
 package main
 
 import (
+	"fmt"
 	"math"
+	"sort"
 	"time"
+
+	"projectparty/store"
 )
 
 type Player struct {
-	game         *Game
-	nick         string
-	id           int
-	xPos         float64
-	yPos         float64
-	angle        int
-	eventQueue   []*PlayerEvent
-	alive        bool
-	currSpeed    float64
-	is_reloading bool
-	score        int
+	game           *Game
+	nick           string
+	id             int
+	xPos           float64
+	yPos           float64
+	angle          int
+	eventQueue     []*PlayerEvent
+	alive          bool
+	currSpeed      float64
+	reloadedAt     time.Time // When the player can shoot again
+	score          int
+	health         float64
+	kills          int            // Kills over the whole game, used to break ties in score
+	roundsWon      int            // Rounds survived as the last man standing, used to break ties in score
+	team           int            // Team the player plays in, noTeam in the free-for-all mode
+	preferredTeam  int            // Team chosen on the controller, noTeam if the player should be auto-balanced
+	diedAt         time.Time      // When the player was killed, zero while he's alive
+	protectedUntil time.Time      // Until when the player is immune to shots after respawning
+	ready          bool           // Whether the player has confirmed he is ready to start in the lobby
+	profile        *store.Profile // Profile the player joined with, nil if he plays anonymously
+	stats          playerStats    // What the player did in the current round and in the whole game
 }
 
 type PlayerEvent struct {
@@ -26,7 +40,9 @@ type PlayerEvent struct {
 }
 
 func NewPlayer(game *Game, nick string, xPos float64, yPos float64) *Player {
-	return &Player{game, nick, len(game.players), xPos, yPos, 0, make([]*PlayerEvent, 0), true, 0, false, 0}
+	id := game.nextPlayerId
+	game.nextPlayerId++
+	return &Player{game, nick, id, xPos, yPos, 0, make([]*PlayerEvent, 0), true, 0, time.Time{}, 0, 1, 0, 0, noTeam, noTeam, time.Time{}, time.Time{}, false, nil, playerStats{}}
 }
 
 func (p *Player) queueEvent(moveSpeed float64, moveAngle int, shotAngle int) {
@@ -38,7 +54,7 @@ func (p *Player) processLastEvent() {
 	var moveAngle int
 
 	if len(p.eventQueue) == 0 {
-		p.currSpeed = math.Max(p.currSpeed-slowDown, 0)
+		p.currSpeed = math.Max(p.currSpeed-slowDown*p.game.settings.moveSpeed(), 0)
 		moveSpeed = p.currSpeed
 		moveAngle = p.angle
 	} else {
@@ -65,8 +81,8 @@ func (p *Player) move(moveSpeed float64, moveAngle int) {
 	p.currSpeed = moveSpeed
 
 	// fmt.Printf("Player moving at speed %f at angle %d\n", moveSpeed, moveAngle)
-	newXPos := moveSpeed*globalMoveSpeed*math.Cos(float64(p.angle)*math.Pi/180.0) + p.xPos
-	newYPos := moveSpeed*globalMoveSpeed*math.Sin(float64(p.angle)*math.Pi/180.0) + p.yPos
+	newXPos := moveSpeed*p.game.settings.moveSpeed()*math.Cos(float64(p.angle)*math.Pi/180.0) + p.xPos
+	newYPos := moveSpeed*p.game.settings.moveSpeed()*math.Sin(float64(p.angle)*math.Pi/180.0) + p.yPos
 
 	for _, wall := range p.game.mapData.Walls {
 		for i := 0; i < len(wall)-1; i += 2 {
@@ -124,35 +140,110 @@ func betterModulo(a, b int) int {
 }
 
 func (p *Player) shoot(shotAngle int) {
-	if p.is_reloading {
+	if p.game.now().Before(p.reloadedAt) {
 		return
 	}
 	if shotAngle < 0 {
 		return
 	}
 
+	p.protectedUntil = time.Time{} // Shooting gives up the spawn protection
+
 	// fmt.Printf("Player shooting at angle %d\n", shotAngle)
-	currShot := Shot{p.game.shotsFired + 1, p, p.xPos + math.Cos(float64(shotAngle)*math.Pi/180.0)*globalShotSpeed, p.yPos + math.Sin(float64(shotAngle)*math.Pi/180.0)*globalShotSpeed, shotAngle}
+	shotSpeed := p.game.settings.shotSpeed()
+	currShot := Shot{p.game.shotsFired + 1, p, p.xPos + math.Cos(float64(shotAngle)*math.Pi/180.0)*shotSpeed, p.yPos + math.Sin(float64(shotAngle)*math.Pi/180.0)*shotSpeed, shotAngle, shotSpeed, p.xPos, p.yPos}
 	p.game.shotBank.addShot <- currShot
 	p.game.shotsFired++
+	if p.game.state == stateInRound {
+		p.stats.shotFired()
+	}
 
-	p.is_reloading = true
-	go func() {
-		time.Sleep(reloadTime)
-		p.is_reloading = false
-	}()
+	p.reloadedAt = p.game.now().Add(p.game.settings.reload())
 
 }
 
 func (p *Player) kill() {
 	p.alive = false
+	p.health = 0
+	p.diedAt = p.game.now()
+	p.stats.death()
+	p.game.mode.onDeath(p.game, p)
+}
+
+// damage takes the given amount of health from the player, killing him when none is left
+func (p *Player) damage(amount float64) {
+	p.health -= amount
+	if p.health <= 0 {
+		p.kill()
+		fmt.Fprintln(p.game.logger, "Player with id ", p.id, " died outside of the zone")
+	}
 }
 
 func (p *Player) respawn() {
-	lowerRollBound := (len(p.game.mapData.SpawnPoints) / len(p.game.players)) * p.id
-	upperRollBound := (len(p.game.mapData.SpawnPoints) / len(p.game.players)) * (p.id + 1)
-	rollIndex := (rng.Intn(upperRollBound-lowerRollBound) + lowerRollBound) % len(p.game.mapData.SpawnPoints)
-	p.xPos = p.game.mapData.SpawnPoints[rollIndex].X
-	p.yPos = p.game.mapData.SpawnPoints[rollIndex].Y
+	p.spawnAt(p.game.mode.spawnIndex(p.game, p))
+}
+
+// spawnAt brings the player back to life at the spawn point with the given index,
+// anywhere a player fits if the map has no such spawn point
+func (p *Player) spawnAt(rollIndex int) {
+	if rollIndex < 0 || rollIndex >= len(p.game.mapData.SpawnPoints) {
+		p.xPos, p.yPos = p.game.mapData.randomOpenPoint(p.game.rng)
+	} else {
+		p.xPos = p.game.mapData.SpawnPoints[rollIndex].X
+		p.yPos = p.game.mapData.SpawnPoints[rollIndex].Y
+	}
 	p.alive = true
+	p.health = 1
+	p.diedAt = time.Time{}
+}
+
+// separatePlayers pushes apart every pair of living players whose bodies overlap.
+// Displacements are computed from the positions before any of them is applied and players are visited in id order,
+// so the result doesn't depend on the iteration order of g.players
+func (g *Game) separatePlayers() {
+	alive := make([]*Player, 0, len(g.players))
+	for _, player := range g.players {
+		if player.alive {
+			alive = append(alive, player)
+		}
+	}
+	sort.Slice(alive, func(i, j int) bool { return alive[i].id < alive[j].id })
+
+	xShift := make([]float64, len(alive))
+	yShift := make([]float64, len(alive))
+	for i := 0; i < len(alive); i++ {
+		for j := i + 1; j < len(alive); j++ {
+			dx := alive[j].xPos - alive[i].xPos
+			dy := alive[j].yPos - alive[i].yPos
+			distance := math.Hypot(dx, dy)
+			overlap := 2*playerRadius - distance
+			if overlap <= 0 {
+				continue
+			}
+
+			// Players standing exactly on top of each other are split along the x axis, lower id to the left
+			dirX, dirY := 1.0, 0.0
+			if distance > 0 {
+				dirX, dirY = dx/distance, dy/distance
+			}
+
+			shift := overlap / 2 * (1 + playerPush)
+			xShift[i] -= dirX * shift
+			yShift[i] -= dirY * shift
+			xShift[j] += dirX * shift
+			yShift[j] += dirY * shift
+		}
+	}
+
+	for i, player := range alive {
+		if xShift[i] == 0 && yShift[i] == 0 {
+			continue
+		}
+		newXPos := player.xPos + xShift[i]
+		newYPos := player.yPos + yShift[i]
+		if g.mapData.wallCollision(newXPos, newYPos, playerRadius) {
+			continue // Being pushed into a wall leaves the player where he is, the other one still gets away
+		}
+		player.xPos, player.yPos = newXPos, newYPos
+	}
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"projectparty/store"
+)
+
+// colourPattern matches the colours players can choose, hex RGB with an optional leading #
+var colourPattern = regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`)
+
+// profileRequest is what a controller asked for its profile in the query of the controller socket. The profile
+// is only created or changed once the game admits the controller, so that rejected joins leave nothing behind
+type profileRequest struct {
+	existing *store.Profile // Profile the token was issued for, nil if a new profile is registered
+	nick     string         // New name of the profile, empty to keep the current one
+	colour   string         // New colour of the profile, empty to keep the current one
+	avatar   int            // New avatar of the profile, -1 to keep the current one
+}
+
+// parseProfileRequest reads the profile a controller wants to join with from the query of the controller socket,
+// nil being returned for anonymous players. The query parameters are:
+//		token - join with the profile which was issued this token
+//		register - "1" to create a new profile named after the nick, the controller is sent its token
+//		nick, colour, avatar - change the name, colour (hex RGB) and avatar (integer) of the profile
+func parseProfileRequest(query url.Values) (*profileRequest, error) {
+	token := query.Get("token")
+	if token == "" && query.Get("register") != "1" {
+		return nil, nil
+	}
+
+	request := &profileRequest{nick: query.Get("nick"), colour: query.Get("colour"), avatar: -1}
+	if request.colour != "" && !colourPattern.MatchString(request.colour) {
+		return nil, errors.New("colour must be a hex RGB colour")
+	}
+	request.colour = strings.ToLower(strings.TrimPrefix(request.colour, "#"))
+	if value := query.Get("avatar"); value != "" {
+		var err error
+		if request.avatar, err = strconv.Atoi(value); err != nil || request.avatar < 0 {
+			return nil, errors.New("avatar must be a non-negative integer")
+		}
+	}
+	if len(request.nick) > maxNickLength {
+		return nil, fmt.Errorf("nick can be at most %d characters long", maxNickLength)
+	}
+
+	if token == "" {
+		if request.nick == "" {
+			return nil, errors.New("nick is required")
+		}
+		return request, nil
+	}
+	profile, err := storage.ProfileByToken(token)
+	if err == store.ErrNotFound {
+		return nil, errors.New("unknown profile token")
+	}
+	if err != nil {
+		return nil, err
+	}
+	request.existing = &profile
+	return request, nil
+}
+
+// name returns the nick the player joins the game with
+func (r *profileRequest) name() string {
+	if r.nick == "" && r.existing != nil {
+		return r.existing.Name
+	}
+	return r.nick
+}
+
+// join creates the requested profile or applies the requested changes to the existing one
+func (r *profileRequest) join() (*store.Profile, error) {
+	update := func(profile *store.Profile) {
+		if r.nick != "" {
+			profile.Name = r.nick
+		}
+		if r.colour != "" {
+			profile.Colour = r.colour
+		}
+		if r.avatar >= 0 {
+			profile.Avatar = r.avatar
+		}
+		profile.LastSeen = time.Now()
+	}
+
+	if r.existing != nil {
+		profile, err := storage.UpdateProfile(r.existing.Id, update)
+		return &profile, err
+	}
+
+	token, err := newProfileToken()
+	if err != nil {
+		return nil, err
+	}
+	profile := store.Profile{Token: token, Created: time.Now()}
+	update(&profile)
+	if err := storage.CreateProfile(&profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// newProfileToken generates a random device token for a new profile
+func newProfileToken() (string, error) {
+	token := make([]byte, profileTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// getNewPlayer creates the NewPlayer message announcing a player to the host,
+// players with a profile are followed by its id, colour and avatar:
+//		NewPlayer::id/nick/team
+//		NewPlayer::id/nick/team::profileId/colour/avatar
+func (g *Game) getNewPlayer(p *Player) []byte {
+	message := fmt.Sprintf("NewPlayer::%d/%s/%d", p.id, p.nick, p.team)
+	if p.profile != nil {
+		message += fmt.Sprintf("::%d/%s/%d", p.profile.Id, p.profile.Colour, p.profile.Avatar)
+	}
+	return []byte(message)
+}
+
+// updateProfiles counts the finished game in the profiles of its players, standings being its final standings
+func (g *Game) updateProfiles(standings []Standing) {
+	for _, standing := range standings {
+		for _, player := range standing.players {
+			if player.profile == nil {
+				continue
+			}
+			won := standing.place == 1
+			_, err := storage.UpdateProfile(player.profile.Id, func(profile *store.Profile) {
+				profile.Games++
+				if won {
+					profile.Wins++
+				}
+			})
+			if err != nil {
+				fmt.Fprintf(g.logger, "Updating the profile of player %s failed with error %s\n", player.nick, err)
+			}
+		}
+	}
+}
+
+// hasProfile checks whether a player with the given profile has already joined the game
+func (g *Game) hasProfile(id uint64) bool {
+	for _, player := range g.players {
+		if player.profile != nil && player.profile.Id == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"projectparty/store"
+)
+
+// ratedPlayer is a player with a profile taking part in the update of a single rating after a match
+type ratedPlayer struct {
+	rating store.Rating
+	place  int
+	team   int
+}
+
+// updateRatings updates the global and the mode ratings of the players with a profile after a finished match,
+// standings being its final standings. Players without a profile aren't rated and don't affect the ratings of the others
+func (g *Game) updateRatings(match uint64, standings []Standing) {
+	mode := strings.SplitN(g.modeName, "/", 2)[0]
+	for _, key := range []string{"", mode} {
+		players := make([]*ratedPlayer, 0)
+		for _, standing := range standings {
+			for _, player := range standing.players {
+				if player.profile == nil {
+					continue
+				}
+				rating, err := storage.Rating(player.profile.Id, key)
+				if err == store.ErrNotFound {
+					rating = store.Rating{ProfileId: player.profile.Id, Mode: key, Rating: initialRating, Deviation: maxRatingDeviation}
+				} else if err != nil {
+					fmt.Fprintf(g.logger, "Reading the rating of player %s failed with error %s\n", player.nick, err)
+					return
+				}
+				players = append(players, &ratedPlayer{rating, standing.place, player.team})
+			}
+		}
+		if len(players) < 2 {
+			return // Nobody to be compared with
+		}
+
+		// Deviations grow with the real time between matches, like the dates of the stored matches
+		ratings, changes := rateMatch(players, match, time.Now())
+		if err := storage.SaveRatings(ratings, changes); err != nil {
+			fmt.Fprintf(g.logger, "Saving the ratings of game with id %d failed with error %s\n", g.id, err)
+			return
+		}
+	}
+}
+
+// rateMatch computes the new ratings with the Glicko system, the match being a rating period in which every player
+// played against each of the others who aren't his teammates, winning against the ones who placed worse than him
+func rateMatch(players []*ratedPlayer, match uint64, now time.Time) ([]store.Rating, []store.RatingChange) {
+	// The longer a player hasn't played, the less certain his rating is
+	for _, p := range players {
+		if !p.rating.Updated.IsZero() {
+			idle := now.Sub(p.rating.Updated).Hours() / 24
+			p.rating.Deviation = math.Min(math.Sqrt(p.rating.Deviation*p.rating.Deviation+ratingDecay*ratingDecay*idle), maxRatingDeviation)
+		}
+	}
+
+	q := math.Ln10 / 400
+	ratings := make([]store.Rating, 0, len(players))
+	changes := make([]store.RatingChange, 0, len(players))
+	for _, p := range players {
+		variance, improvement := 0.0, 0.0
+		for _, opponent := range players {
+			if opponent == p || (p.team != noTeam && p.team == opponent.team) {
+				continue
+			}
+			impact := 1 / math.Sqrt(1+3*q*q*opponent.rating.Deviation*opponent.rating.Deviation/(math.Pi*math.Pi))
+			expected := 1 / (1 + math.Pow(10, -impact*(p.rating.Rating-opponent.rating.Rating)/400))
+			result := 0.5
+			if p.place < opponent.place {
+				result = 1
+			} else if p.place > opponent.place {
+				result = 0
+			}
+			variance += q * q * impact * impact * expected * (1 - expected)
+			improvement += impact * (result - expected)
+		}
+
+		rating := p.rating
+		if variance > 0 {
+			precision := 1/(p.rating.Deviation*p.rating.Deviation) + variance
+			rating.Rating += q / precision * improvement
+			rating.Deviation = math.Max(math.Sqrt(1/precision), minRatingDeviation)
+		}
+		rating.Games++
+		rating.Updated = now
+		ratings = append(ratings, rating)
+		changes = append(changes, store.RatingChange{
+			ProfileId: rating.ProfileId,
+			Mode:      rating.Mode,
+			Match:     match,
+			Place:     p.place,
+			Before:    p.rating.Rating,
+			After:     rating.Rating,
+			Deviation: rating.Deviation,
+			Time:      now,
+		})
+	}
+
+	return ratings, changes
+}
+
+// RatedProfile is what everybody can see of a profile, never including its token
+type RatedProfile struct {
+	Id     uint64 `json:"id"`
+	Name   string `json:"name"`
+	Colour string `json:"colour"`
+	Avatar int    `json:"avatar"`
+	Games  int    `json:"games"`
+	Wins   int    `json:"wins"`
+}
+
+// LeaderboardEntry is a single place in a leaderboard
+type LeaderboardEntry struct {
+	Place   int          `json:"place"`
+	Profile RatedProfile `json:"profile"`
+	Rating  store.Rating `json:"rating"`
+}
+
+// PlayerRatings holds everything about the ratings of a single player
+type PlayerRatings struct {
+	Profile RatedProfile         `json:"profile"`
+	Ratings []store.Rating       `json:"ratings"` // The global rating first, then the ones of the modes
+	History []store.RatingChange `json:"history"` // The latest first
+}
+
+// serveRatings writes the ratings as JSON, the query parameters being:
+//		mode - the leaderboard of a mode like "ffa" or "teams", the global one if it's missing
+//		limit - how many of the best players to list, at most maxLeaderboardSize
+//		profile - the id of a profile to list the ratings and the rating history of instead of the leaderboard
+func serveRatings(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := defaultLeaderboardSize
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxLeaderboardSize {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxLeaderboardSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var result interface{}
+	var err error
+	if value := query.Get("profile"); value != "" {
+		id, parseErr := strconv.ParseUint(value, 10, 64)
+		if parseErr != nil {
+			http.Error(w, "profile must be an integer", http.StatusBadRequest)
+			return
+		}
+		result, err = playerRatings(id, limit)
+	} else {
+		result, err = leaderboard(query.Get("mode"), limit)
+	}
+
+	if err == store.ErrNotFound {
+		http.Error(w, "profile doesn't exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// leaderboard lists the best players in a mode, empty for the global leaderboard
+func leaderboard(mode string, limit int) ([]LeaderboardEntry, error) {
+	ratings, err := storage.Leaderboard(mode, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]LeaderboardEntry, 0, len(ratings))
+	for i, rating := range ratings {
+		profile, err := storage.Profile(rating.ProfileId)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, LeaderboardEntry{i + 1, ratedProfile(profile), rating})
+	}
+	return result, nil
+}
+
+// playerRatings lists the ratings of a player and at most limit latest changes to them
+func playerRatings(id uint64, limit int) (PlayerRatings, error) {
+	profile, err := storage.Profile(id)
+	if err != nil {
+		return PlayerRatings{}, err
+	}
+	ratings, err := storage.Ratings(id)
+	if err != nil {
+		return PlayerRatings{}, err
+	}
+	history, err := storage.RatingHistory(id, limit)
+	if err != nil {
+		return PlayerRatings{}, err
+	}
+	return PlayerRatings{ratedProfile(profile), ratings, history}, nil
+}
+
+func ratedProfile(profile store.Profile) RatedProfile {
+	return RatedProfile{profile.Id, profile.Name, profile.Colour, profile.Avatar, profile.Games, profile.Wins}
+}
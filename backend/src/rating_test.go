@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"projectparty/store"
+)
+
+func TestRateMatch(t *testing.T) {
+	now := time.Date(2020, 5, 1, 18, 0, 0, 0, time.UTC)
+	rated := func(id uint64, rating, deviation float64, place, team int, updated time.Time) *ratedPlayer {
+		return &ratedPlayer{store.Rating{ProfileId: id, Rating: rating, Deviation: deviation, Updated: updated}, place, team}
+	}
+
+	tests := []struct {
+		name          string
+		players       []*ratedPlayer
+		wantRating    float64 // New rating and deviation of the first player
+		wantDeviation float64
+	}{
+		{
+			// The example worked through in Glickman's description of the Glicko system, which rounds the result to 1464 and 151.4
+			"glickman example",
+			[]*ratedPlayer{
+				rated(1, 1500, 200, 3, noTeam, time.Time{}),
+				rated(2, 1400, 30, 4, noTeam, time.Time{}),
+				rated(3, 1550, 100, 2, noTeam, time.Time{}),
+				rated(4, 1700, 300, 1, noTeam, time.Time{}),
+			},
+			1464.11, 151.40,
+		},
+		{
+			"new players, win",
+			[]*ratedPlayer{rated(1, initialRating, maxRatingDeviation, 1, noTeam, time.Time{}), rated(2, initialRating, maxRatingDeviation, 2, noTeam, time.Time{})},
+			1662.21, 290.23,
+		},
+		{
+			"new players, loss",
+			[]*ratedPlayer{rated(1, initialRating, maxRatingDeviation, 2, noTeam, time.Time{}), rated(2, initialRating, maxRatingDeviation, 1, noTeam, time.Time{})},
+			1337.79, 290.23,
+		},
+		{
+			"new players, shared place",
+			[]*ratedPlayer{rated(1, initialRating, maxRatingDeviation, 1, noTeam, time.Time{}), rated(2, initialRating, maxRatingDeviation, 1, noTeam, time.Time{})},
+			1500, 290.23,
+		},
+		{
+			// Teammates aren't compared, so only the deviation grows back after 10 days without a match
+			"teammates only",
+			[]*ratedPlayer{rated(1, 1600, 50, 1, 0, now.Add(-240*time.Hour)), rated(2, 1400, 50, 1, 0, now.Add(-240*time.Hour))},
+			1600, math.Sqrt(50*50 + ratingDecay*ratingDecay*10),
+		},
+		{
+			"deviation at its floor",
+			[]*ratedPlayer{rated(1, 1500, minRatingDeviation, 1, noTeam, now), rated(2, 1500, minRatingDeviation, 2, noTeam, now)},
+			1502.56, minRatingDeviation,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ratings, changes := rateMatch(test.players, 9, now)
+			if len(ratings) != len(test.players) || len(changes) != len(test.players) {
+				t.Fatalf("got %d ratings and %d changes for %d players", len(ratings), len(changes), len(test.players))
+			}
+
+			got := ratings[0]
+			if got.ProfileId != 1 || got.Games != 1 || !got.Updated.Equal(now) {
+				t.Errorf("rating = %+v, want profile 1 with one game updated at %v", got, now)
+			}
+			if math.Abs(got.Deviation-test.wantDeviation) > 0.01 {
+				t.Errorf("deviation = %.2f, want %.2f", got.Deviation, test.wantDeviation)
+			}
+			if math.Abs(got.Rating-test.wantRating) > 0.01 {
+				t.Errorf("rating = %.2f, want %.2f", got.Rating, test.wantRating)
+			}
+			if changes[0].Match != 9 || changes[0].Before != test.players[0].rating.Rating || changes[0].After != got.Rating {
+				t.Errorf("change = %+v, doesn't match the rating %+v", changes[0], got)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// replayDir holds the directory the replays are written to, recording is turned off if it's empty
+var replayDir = flag.String("replays", "replays", "directory for match replays, empty to turn recording off")
+
+// A replay is a file with one ReplayRecord in JSON per line, written as the match goes on so that a crash still
+// leaves everything up to the last few ticks. The kinds of records and their data are:
+//		header - ReplayHeader, always the first record
+//		roster - []ReplayPlayer, the players the game started with
+//		round - ReplayRound, the map of a round which has just begun
+//		input - ReplayInput, a message from a controller accepted by the game, it takes effect in the next tick
+//		kill - ReplayKill, a player shot by another one
+//		snapshot - string, the gameplay packet sent to the screens, recorded every replaySnapshotTicks ticks
+//		event - string, a message sent to the host
+//		end - no data, the game has ended and the replay is complete
+type ReplayRecord struct {
+	Kind string          `json:"kind"`
+	Tick uint64          `json:"tick"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// ReplayHeader holds everything needed to set the game up again
+type ReplayHeader struct {
+	Version  int      `json:"version"`
+	GameId   uint64   `json:"gameId"`
+	Seed     int64    `json:"seed"`
+	Mode     string   `json:"mode"` // Params of the Mode command
+	Settings Settings `json:"settings"`
+	Started  int64    `json:"started"` // Milliseconds since Unix EPOCH
+}
+
+type ReplayPlayer struct {
+	Id   int    `json:"id"`
+	Nick string `json:"nick"`
+	Team int    `json:"team"`
+}
+
+type ReplayRound struct {
+	Round int             `json:"round"`
+	Map   json.RawMessage `json:"map"`
+}
+
+type ReplayInput struct {
+	Player  int    `json:"player"`
+	Message string `json:"message"`
+}
+
+type ReplayKill struct {
+	Shooter int `json:"shooter"`
+	Target  int `json:"target"`
+}
+
+// Recorder writes the replay of a single game, all of its methods do nothing on a nil Recorder
+// so that games which aren't recorded don't have to check
+type Recorder struct {
+	path    string
+	records chan ReplayRecord
+	lock    sync.Mutex // Keeps records from being queued after the recorder is closed
+	closed  bool
+}
+
+// NewRecorder creates the replay file of the game and starts writing to it
+func NewRecorder(g *Game) (*Recorder, error) {
+	if *replayDir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(*replayDir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(*replayDir, fmt.Sprintf("%d-game-%d.replay", time.Now().Unix(), g.id))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{path: path, records: make(chan ReplayRecord, 1024)}
+	go r.run(file)
+	return r, nil
+}
+
+// run writes the records to the file, flushing whenever it has nothing else to do
+func (r *Recorder) run(file *os.File) {
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	defer func() {
+		writer.Flush()
+		file.Close()
+		log.Printf("replay %s complete", r.path)
+	}()
+
+	for record := range r.records {
+		if err := encoder.Encode(record); err != nil {
+			log.Printf("writing replay %s failed: %v", r.path, err)
+			return
+		}
+		if len(r.records) == 0 {
+			writer.Flush()
+		}
+	}
+}
+
+// record queues a record with the data encoded as JSON
+func (r *Recorder) record(kind string, tick uint64, data interface{}) {
+	if r == nil {
+		return
+	}
+
+	var raw json.RawMessage
+	if data != nil {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			log.Printf("encoding %s record failed: %v", kind, err)
+			return
+		}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.closed {
+		r.records <- ReplayRecord{kind, tick, raw}
+	}
+}
+
+// close records the end of the game and finishes the file
+func (r *Recorder) close(tick uint64) {
+	if r == nil {
+		return
+	}
+
+	r.record("end", tick, nil)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closed = true
+	close(r.records)
+}
+
+// startRecording creates the recorder of the game once it starts, with its header and roster
+func (g *Game) startRecording() {
+	recorder, err := NewRecorder(g)
+	if err != nil {
+		fmt.Fprintf(g.logger, "Recording game with id %d failed with error %s\n", g.id, err)
+		return
+	}
+	if recorder == nil {
+		return
+	}
+
+	g.recorder = recorder
+	g.recorder.record("header", g.tick, ReplayHeader{replayVersion, g.id, g.seed, g.modeName, g.settings, time.Now().UnixNano() / int64(time.Millisecond)})
+	roster := make([]ReplayPlayer, 0, len(g.players))
+	for _, player := range g.playersById() {
+		roster = append(roster, ReplayPlayer{player.id, player.nick, player.team})
+	}
+	g.recorder.record("roster", g.tick, roster)
+}
+
+// sendInfo sends the message to the host and records it as an event
+func (g *Game) sendInfo(message []byte) {
+	g.recorder.record("event", g.tick, string(message))
+	g.info.send(message)
+}
@@ -1,20 +1,82 @@
 package main
 
 import (
+	"bytes"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// A middleman between the websocket connection for the game screen app and the game.
+// A middleman between the websocket connection for a game screen or a spectator and the game.
 type Screen struct {
 	game *Game
 
 	conn *websocket.Conn
 
-	input chan []byte
+	input  chan []byte // Holds only the latest gameplay packet, older ones are replaced as the screen only needs the current state
+	missed int         // Gameplay packets replaced in a row before the screen took any, used only by the Broadcaster
+
+	rtt     int64 // Last measured round trip time in nanoseconds, accessed atomically
+	maxRate int64 // Most gameplay packets per second the screen asked for, 0 if it didn't, accessed atomically
+
+	// Used only by the Broadcaster
+	interval  time.Duration // Current time between two gameplay packets sent to the screen
+	lastSent  time.Time
+	dropped   int       // Gameplay packets replaced since the rate was last adjusted
+	adaptedAt time.Time // When the rate was last adjusted
+}
+
+// readPump reads the messages sent by the screen, which are the replies to pings and rate requests.
+//
+// The application runs readPump in a per-connection goroutine. The application
+// ensures that there is at most one reader on a connection by executing all
+// reads from this goroutine.
+func (s *Screen) readPump() {
+	defer func() {
+		s.conn.Close()
+	}()
+	s.conn.SetReadLimit(maxMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(payload string) error {
+		s.conn.SetReadDeadline(time.Now().Add(pongWait))
+		if sent, err := strconv.ParseInt(payload, 10, 64); err == nil {
+			atomic.StoreInt64(&s.rtt, time.Now().UnixNano()-sent)
+		}
+		return nil
+	})
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("error: %v", err)
+			}
+			break
+		}
+		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
+		s.processMessage(string(message))
+	}
+}
+
+// processMessage processes the messages sent by the screen, currently only "Rate::${packetsPerSecond}",
+// 0 letting the server choose the rate on its own again
+func (s *Screen) processMessage(message string) {
+	parts := strings.SplitN(message, "::", 2)
+	if len(parts) != 2 || parts[0] != "Rate" {
+		log.Printf("unknown message from a screen of game %d: %s", s.game.id, message)
+		return
+	}
+
+	rate, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || rate < 0 {
+		log.Printf("wrong rate from a screen of game %d: %s", s.game.id, parts[1])
+		return
+	}
+	atomic.StoreInt64(&s.maxRate, rate)
 }
 
 // writePump pumps messages from the game to the websocket connection.
@@ -23,10 +85,10 @@ type Screen struct {
 // application ensures that there is at most one writer to a connection by
 // executing all writes from this goroutine.
 func (s *Screen) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(rttProbePeriod)
 	defer func() {
 		ticker.Stop()
-		s.game.unregisterScreen <- true
+		s.game.screens.unregister <- s
 		s.conn.Close()
 	}()
 	for {
@@ -39,40 +101,97 @@ func (s *Screen) writePump() {
 				return
 			}
 
-			w, err := s.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			n := len(s.input)
-			for i := 0; i < n; i++ {
-				w.Write(newline)
-				w.Write(<-s.input)
-			}
-
-			if err := w.Close(); err != nil {
+			if err := s.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
+			atomic.AddUint64(&metrics.FramesSent, 1)
 		case <-ticker.C:
+			// The screen echoes the time of sending back, which gives the round trip time
 			s.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := s.conn.WriteMessage(websocket.PingMessage, []byte(strconv.FormatInt(time.Now().UnixNano(), 10))); err != nil {
 				return
 			}
 		}
 	}
 }
 
+// offerFrame passes the gameplay packet on to the screen if it's due one at its current rate, adjusting the rate
+// every now and then. Returns false once the screen has missed too many packets in a row and should be disconnected
+func (s *Screen) offerFrame(message []byte, now time.Time) bool {
+	if now.Sub(s.adaptedAt) >= rateAdaptPeriod {
+		s.adaptRate()
+		s.adaptedAt = now
+	}
+
+	// Half a tick of tolerance keeps the jitter of the ticker from skipping packets
+	if now.Sub(s.lastSent) < s.interval-fastRefresh/2 {
+		return true
+	}
+	s.lastSent = now
+	return s.sendFrame(message)
+}
+
+// adaptRate slows the packets down for screens which are far away or don't keep up and speeds them up again
+// for the ones which do, never faster than the screen asked for nor than the simulation runs
+func (s *Screen) adaptRate() {
+	fastest := time.Duration(fastRefresh)
+	if rate := atomic.LoadInt64(&s.maxRate); rate > 0 && time.Second/time.Duration(rate) > fastest {
+		fastest = time.Second / time.Duration(rate)
+	}
+	rtt := time.Duration(atomic.LoadInt64(&s.rtt))
+
+	switch {
+	case s.dropped > 0 || rtt > highRTT:
+		s.interval *= 2
+	case rtt < lowRTT:
+		s.interval /= 2
+	}
+
+	if s.interval < fastest {
+		s.interval = fastest
+	}
+	if s.interval > slowRefresh {
+		s.interval = slowRefresh
+	}
+	s.dropped = 0
+}
+
+// sendFrame queues a gameplay packet for the screen without ever blocking, replacing the previous one if the screen
+// hasn't taken it yet. Returns false once the screen has missed too many packets in a row and should be disconnected
+func (s *Screen) sendFrame(message []byte) bool {
+	select {
+	case s.input <- message:
+		s.missed = 0
+		return true
+	default:
+	}
+
+	select {
+	case <-s.input:
+		s.missed++
+		s.dropped++
+		atomic.AddUint64(&metrics.FramesDropped, 1)
+	default: // The screen has just taken it
+	}
+	select {
+	case s.input <- message:
+	default:
+	}
+
+	return s.missed < maxDroppedFrames
+}
+
 func serveScreenWs(game *Game, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	screen := &Screen{game: game, conn: conn, input: make(chan []byte, 256)}
-	screen.game.registerScreen <- screen
+	screen := &Screen{game: game, conn: conn, input: make(chan []byte, 1), interval: fastRefresh}
+	screen.game.screens.register <- screen
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
 	go screen.writePump()
+	go screen.readPump()
 }
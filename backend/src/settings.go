@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Settings holds the rules of a single game, the host can change them in the lobby
+type Settings struct {
+	Rounds       int     `json:"rounds"`       // How many rounds are played before the game ends
+	ReloadTime   int     `json:"reloadTime"`   // Milliseconds between shots
+	MoveSpeed    float64 `json:"moveSpeed"`    // Multiplier of the speed at which players walk
+	ShotSpeed    float64 `json:"shotSpeed"`    // Multiplier of the speed at which shots travel
+	FriendlyFire bool    `json:"friendlyFire"` // Whether shots can kill teammates
+	TimeLimit    int     `json:"timeLimit"`    // Seconds a round lasts before it's decided, 0 keeps the default of the game mode
+	BreakTime    int     `json:"breakTime"`    // Seconds between rounds
+	Prize        int     `json:"prize"`        // Points for winning a round
+	MapWidth     int     `json:"mapWidth"`     // Width of the generated maps in cells
+	MapHeight    int     `json:"mapHeight"`    // Height of the generated maps in cells
+	MapFill      int     `json:"mapFill"`      // How many percent of the generated maps are initially filled with walls
+}
+
+// Bounds of the settings the host can choose
+const (
+	minRounds, maxRounds         = 1, 20
+	minReloadTime, maxReloadTime = 100, 2000
+	minSpeed, maxSpeed           = 0.5, 2.0
+	minTimeLimit, maxTimeLimit   = 30, 900
+	minBreakTime, maxBreakTime   = 1, 10
+	minPrize, maxPrize           = 0, 20
+	minMapSize, maxMapSize       = 32, 96
+	minMapFill, maxMapFill       = 40, 52
+)
+
+// defaultSettings returns the settings every game starts with
+func defaultSettings() Settings {
+	return Settings{
+		Rounds:       maxRoundCount,
+		ReloadTime:   int(reloadTime / time.Millisecond),
+		MoveSpeed:    1,
+		ShotSpeed:    1,
+		FriendlyFire: false,
+		TimeLimit:    0,
+		BreakTime:    int(roundBreakTime / time.Second),
+		Prize:        lastManStandingPrize,
+		MapWidth:     mapWidth,
+		MapHeight:    mapHeight,
+		MapFill:      mapFillPercentage,
+	}
+}
+
+// validate checks whether all of the settings are within their bounds
+func (s Settings) validate() error {
+	switch {
+	case s.Rounds < minRounds || s.Rounds > maxRounds:
+		return fmt.Errorf("rounds must be between %d and %d", minRounds, maxRounds)
+	case s.ReloadTime < minReloadTime || s.ReloadTime > maxReloadTime:
+		return fmt.Errorf("reloadTime must be between %d and %d", minReloadTime, maxReloadTime)
+	case s.MoveSpeed < minSpeed || s.MoveSpeed > maxSpeed:
+		return fmt.Errorf("moveSpeed must be between %.1f and %.1f", minSpeed, maxSpeed)
+	case s.ShotSpeed < minSpeed || s.ShotSpeed > maxSpeed:
+		return fmt.Errorf("shotSpeed must be between %.1f and %.1f", minSpeed, maxSpeed)
+	case s.TimeLimit != 0 && (s.TimeLimit < minTimeLimit || s.TimeLimit > maxTimeLimit):
+		return fmt.Errorf("timeLimit must be 0 or between %d and %d", minTimeLimit, maxTimeLimit)
+	case s.BreakTime < minBreakTime || s.BreakTime > maxBreakTime:
+		return fmt.Errorf("breakTime must be between %d and %d", minBreakTime, maxBreakTime)
+	case s.Prize < minPrize || s.Prize > maxPrize:
+		return fmt.Errorf("prize must be between %d and %d", minPrize, maxPrize)
+	case s.MapWidth < minMapSize || s.MapWidth > maxMapSize || s.MapHeight < minMapSize || s.MapHeight > maxMapSize:
+		return fmt.Errorf("mapWidth and mapHeight must be between %d and %d", minMapSize, maxMapSize)
+	case s.MapFill < minMapFill || s.MapFill > maxMapFill:
+		return fmt.Errorf("mapFill must be between %d and %d", minMapFill, maxMapFill)
+	}
+
+	return nil
+}
+
+// moveSpeed returns the distance a player running at full speed covers in a single tick
+func (s Settings) moveSpeed() float64 {
+	return globalMoveSpeed * s.MoveSpeed
+}
+
+// shotSpeed returns the distance a shot covers in a single tick
+func (s Settings) shotSpeed() float64 {
+	return globalShotSpeed * s.ShotSpeed
+}
+
+// reload returns the time between shots
+func (s Settings) reload() time.Duration {
+	return time.Duration(s.ReloadTime) * time.Millisecond
+}
+
+// roundBreak returns the time between rounds
+func (s Settings) roundBreak() time.Duration {
+	return time.Duration(s.BreakTime) * time.Second
+}
+
+// timeLimit returns how long a round lasts, modeDefault being used if the host hasn't set a limit
+func (s Settings) timeLimit(modeDefault time.Duration) time.Duration {
+	if s.TimeLimit == 0 {
+		return modeDefault
+	}
+	return time.Duration(s.TimeLimit) * time.Second
+}
+
+// setSettings processes the Settings command sent by the host, params being a JSON object with any of the settings,
+// the ones left out keep their current values
+func (g *Game) setSettings(params string) error {
+	if g.state != stateLobby {
+		return errors.New("settings can only be changed before the game starts")
+	}
+
+	settings := g.settings
+	if err := json.Unmarshal([]byte(params), &settings); err != nil {
+		return fmt.Errorf("settings must be a JSON object: %s", err)
+	}
+	if err := settings.validate(); err != nil {
+		return err
+	}
+
+	g.settings = settings
+	return nil
+}
+
+// getSettingsUpdate creates a message with all the current settings, sent to every client after they change
+func (g *Game) getSettingsUpdate() []byte {
+	data, _ := json.Marshal(g.settings)
+	return append([]byte("Settings::"), data...)
+}
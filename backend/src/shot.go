@@ -10,9 +10,12 @@ type Shot struct {
 	xPos  float64
 	yPos  float64
 	angle int
+	speed float64 // Distance covered in a single tick
+	xFrom float64 // Where the shot was fired from
+	yFrom float64
 }
 
 func (s *Shot) move() {
-	s.xPos = globalShotSpeed*math.Cos(float64(s.angle)*math.Pi/180.0) + s.xPos
-	s.yPos = globalShotSpeed*math.Sin(float64(s.angle)*math.Pi/180.0) + s.yPos
+	s.xPos = s.speed*math.Cos(float64(s.angle)*math.Pi/180.0) + s.xPos
+	s.yPos = s.speed*math.Sin(float64(s.angle)*math.Pi/180.0) + s.yPos
 }
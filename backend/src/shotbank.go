@@ -9,16 +9,21 @@ type ShotBank struct {
 	moveShots  chan bool
 	addShot 	 chan Shot
 	getShots   chan GetShotsRequest
+	stop       chan bool // Closed once the bank is no longer used, which ends Run
 }
 
 func NewShotBank() ShotBank {
-	return ShotBank{make(chan uint64), make(chan bool), make(chan Shot), make(chan GetShotsRequest)}
+	return ShotBank{make(chan uint64), make(chan bool), make(chan Shot), make(chan GetShotsRequest), make(chan bool)}
 }
 
-func (sb *ShotBank) Run() {
+// Run owns the shots of the bank until the bank is stopped. It works on a copy of the bank, so that replacing the bank
+// of a game for a new round leaves the old goroutine with the old channels instead of having it compete for the new ones
+func (sb ShotBank) Run() {
 	shots := make([]Shot, 0)
 	for {
 		select {
+		case <-sb.stop:
+			return
 		case s := <-sb.addShot:
 			shots = append(shots, s)
 		case <-sb.moveShots:
@@ -40,4 +45,9 @@ func (sb *ShotBank) Run() {
 		}
 	}
 
-}
\ No newline at end of file
+}
+
+// Stop ends the goroutine running the bank, it must be called once the bank is replaced or the game is over
+func (sb ShotBank) Stop() {
+	close(sb.stop)
+}
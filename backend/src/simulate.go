@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// SimulationResult is the output of the simulate command
+type SimulationResult struct {
+	Matches []MatchResult     `json:"matches"`
+	Summary SimulationSummary `json:"summary"`
+}
+
+// MatchResult holds the outcome of a single simulated match
+type MatchResult struct {
+	Seed      int64            `json:"seed"`
+	Ticks     uint64           `json:"ticks"`
+	Rounds    int              `json:"rounds"`
+	TimedOut  bool             `json:"timedOut"` // Whether the match was stopped at simulationTickLimit
+	Standings []StandingResult `json:"standings"`
+	Players   []PlayerResult   `json:"players"`
+}
+
+type StandingResult struct {
+	Place   int   `json:"place"`
+	Score   int   `json:"score"`
+	Team    int   `json:"team"`
+	Players []int `json:"players"`
+}
+
+type PlayerResult struct {
+	Id        int         `json:"id"`
+	Nick      string      `json:"nick"`
+	Team      int         `json:"team"`
+	Score     int         `json:"score"`
+	Kills     int         `json:"kills"`
+	Deaths    int         `json:"deaths"`
+	RoundsWon int         `json:"roundsWon"`
+	Stats     PlayerStats `json:"stats"`
+}
+
+// SimulationSummary holds the balance metrics over all of the matches, players being told apart by their ids,
+// which are the same in every match. As every simulated player plays the same way, an uneven share of victories
+// points at unfair spawns or maps
+type SimulationSummary struct {
+	Players       []PlayerSummary `json:"players"`
+	AverageTicks  float64         `json:"averageTicks"`
+	AverageRounds float64         `json:"averageRounds"`
+	SharedWins    int             `json:"sharedWins"`    // Matches in which several players or teams shared the first place
+	WinRateSpread float64         `json:"winRateSpread"` // Difference between the highest and the lowest win rate of the players
+}
+
+type PlayerSummary struct {
+	Id           int     `json:"id"`
+	Wins         int     `json:"wins"`
+	WinRate      float64 `json:"winRate"`
+	AveragePlace float64 `json:"averagePlace"`
+	AverageScore float64 `json:"averageScore"`
+	Kills        int     `json:"kills"`
+	Deaths       int     `json:"deaths"`
+}
+
+// scriptedPlayer plays a simulated player: it wanders around, changing direction every now and then,
+// and shoots at the closest enemy in range
+type scriptedPlayer struct {
+	moveAngle int
+	turnAt    uint64 // Tick at which the player picks a new direction
+}
+
+// runSimulation runs the simulate command with the given arguments and writes the result to stdout as JSON
+func runSimulation(args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	matches := flags.Int("matches", 10, "number of matches to run")
+	players := flags.Int("players", 4, "number of players in every match")
+	seed := flags.Int64("seed", 0, "seed of the first match, the following ones use the next seeds, 0 for a random one")
+	mode := flags.String("mode", modeFreeForAll, "params of the Mode command")
+	settings := flags.String("settings", "", "settings of the matches as a JSON object, like the Settings command")
+	maps := flags.String("maps", "", "comma separated map files in the format of the map service, the map service is used if empty")
+	bots := flags.String("bots", "", "difficulty of bots playing the matches instead of the scripted players, empty for scripted players")
+	replays := flags.String("replays", "", "directory for replays of the matches, empty to record none")
+	verbose := flags.Bool("verbose", false, "write the log of the games to stderr, keeping the report on stdout clean")
+	flags.Parse(args)
+
+	if *matches < 1 {
+		return errors.New("at least one match has to be run")
+	}
+	if *players < minPlayers || *players > maxPlayers {
+		return fmt.Errorf("players must be between %d and %d", minPlayers, maxPlayers)
+	}
+	if _, ok := botDifficulties[*bots]; *bots != "" && !ok {
+		return fmt.Errorf("unknown difficulty %s", *bots)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	*replayDir = *replays
+
+	loadedMaps := make([]Map, 0)
+	for _, path := range strings.Split(*maps, ",") {
+		if path == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		loadedMaps = append(loadedMaps, createMapFromJson(data))
+	}
+	// The log of the games goes to stderr, so that the result on stdout stays valid JSON
+	var logger io.Writer = ioutil.Discard
+	if *verbose {
+		logger = os.Stderr
+	}
+
+	result := SimulationResult{Matches: make([]MatchResult, 0, *matches)}
+	for i := 0; i < *matches; i++ {
+		match, err := simulateMatch(*seed+int64(i), *players, *bots, *mode, *settings, loadedMaps, logger)
+		if err != nil {
+			return fmt.Errorf("match %d: %s", i, err)
+		}
+		result.Matches = append(result.Matches, match)
+	}
+	result.Summary = summarize(result.Matches, *players)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// simulateMatch sets a game up like a host would and plays it as fast as possible,
+// with bots of the given difficulty or with scripted players if it's empty
+func simulateMatch(seed int64, playerCount int, difficulty, mode, settings string, maps []Map, logger io.Writer) (MatchResult, error) {
+	g, err := newGame(logger)
+	if err != nil {
+		return MatchResult{}, err
+	}
+	g.seed = seed
+	g.rng = rand.New(rand.NewSource(seed))
+	if len(maps) > 0 {
+		g.loadMap = func(settings Settings, seed int32) (Map, error) {
+			return maps[int(seed)%len(maps)], nil
+		}
+	}
+
+	if err := g.setMode(mode); err != nil {
+		return MatchResult{}, err
+	}
+	if settings != "" {
+		if err := g.setSettings(settings); err != nil {
+			return MatchResult{}, err
+		}
+	}
+
+	for i := 0; i < playerCount; i++ {
+		var err error
+		if difficulty != "" {
+			err = g.addBot(difficulty)
+		} else {
+			err = g.addPlayer(&Controller{game: g, nick: fmt.Sprintf("bot%d", i), team: noTeam})
+		}
+		if err != nil {
+			return MatchResult{}, err
+		}
+	}
+	if err := g.start("force"); err != nil {
+		return MatchResult{}, err
+	}
+	if g.state == stateLobby {
+		return MatchResult{}, errors.New("the map could not be loaded")
+	}
+
+	scripts := make(map[*Player]*scriptedPlayer)
+	for _, player := range g.players {
+		if _, ok := g.bots[player]; !ok {
+			scripts[player] = &scriptedPlayer{}
+		}
+	}
+
+	timedOut := false
+	for g.state != stateFinished {
+		if g.tick >= simulationTickLimit {
+			timedOut = true
+			break
+		}
+
+		for _, player := range g.playersById() {
+			if script, ok := scripts[player]; ok && player.alive && g.state == stateInRound {
+				moveSpeed, moveAngle, shotAngle := script.play(g, player)
+				player.queueEvent(moveSpeed, moveAngle, shotAngle)
+			}
+		}
+		g.step()
+	}
+	if timedOut {
+		g.shotBank.Stop()
+		g.recorder.close(g.tick)
+	}
+
+	match := MatchResult{Seed: seed, Ticks: g.tick, Rounds: g.roundCount, TimedOut: timedOut}
+	for _, standing := range g.mode.finalStandings(g) {
+		ids := make([]int, 0, len(standing.players))
+		for _, player := range standing.players {
+			ids = append(ids, player.id)
+		}
+		match.Standings = append(match.Standings, StandingResult{standing.place, standing.score, standing.team, ids})
+	}
+	for _, player := range g.playersById() {
+		match.Players = append(match.Players, PlayerResult{player.id, player.nick, player.team, player.score, player.kills, player.stats.game.Deaths, player.roundsWon, player.stats.game})
+	}
+
+	return match, nil
+}
+
+// play returns the input of the scripted player for the next tick: moveSpeed, moveAngle and shotAngle (-1 for none)
+func (s *scriptedPlayer) play(g *Game, p *Player) (float64, int, int) {
+	if g.tick >= s.turnAt {
+		s.moveAngle = g.rng.Intn(360)
+		s.turnAt = g.tick + uint64(time.Second/refresh) + uint64(g.rng.Intn(int(time.Second/refresh)))
+	}
+
+	shotAngle := -1
+	var target *Player
+	closest := scriptedShotRange
+	for _, other := range g.playersById() {
+		if other == p || !other.alive || g.areTeammates(p, other) {
+			continue
+		}
+		if distance := math.Hypot(other.xPos-p.xPos, other.yPos-p.yPos); distance < closest {
+			target, closest = other, distance
+		}
+	}
+	if target != nil {
+		angle := math.Atan2(target.yPos-p.yPos, target.xPos-p.xPos) * 180 / math.Pi
+		shotAngle = (int(math.Round(angle)) + g.rng.Intn(2*scriptedAimError+1) - scriptedAimError + 360) % 360
+	}
+
+	return 1, s.moveAngle, shotAngle
+}
+
+// summarize computes the balance metrics over all of the matches
+func summarize(matches []MatchResult, playerCount int) SimulationSummary {
+	summary := SimulationSummary{Players: make([]PlayerSummary, playerCount)}
+	for i := range summary.Players {
+		summary.Players[i].Id = i
+	}
+
+	for _, match := range matches {
+		summary.AverageTicks += float64(match.Ticks) / float64(len(matches))
+		summary.AverageRounds += float64(match.Rounds) / float64(len(matches))
+
+		winners := 0
+		for _, standing := range match.Standings {
+			if standing.Place == 1 {
+				winners++
+			}
+			for _, id := range standing.Players {
+				if standing.Place == 1 {
+					summary.Players[id].Wins++
+				}
+				summary.Players[id].AveragePlace += float64(standing.Place) / float64(len(matches))
+			}
+		}
+		if winners > 1 {
+			summary.SharedWins++
+		}
+
+		for _, player := range match.Players {
+			summary.Players[player.Id].AverageScore += float64(player.Score) / float64(len(matches))
+			summary.Players[player.Id].Kills += player.Kills
+			summary.Players[player.Id].Deaths += player.Deaths
+		}
+	}
+
+	lowest, highest := 1.0, 0.0
+	for i := range summary.Players {
+		rate := float64(summary.Players[i].Wins) / float64(len(matches))
+		summary.Players[i].WinRate = rate
+		lowest, highest = math.Min(lowest, rate), math.Max(highest, rate)
+	}
+	summary.WinRateSpread = highest - lowest
+
+	return summary
+}
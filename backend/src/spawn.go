@@ -0,0 +1,293 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"projectparty/nav"
+)
+
+// SpawnPlanner picks the spawn points of a single round. It works on a sample of the spawn points of the map,
+// spread over it as evenly as possible, and on the lengths of the paths between them, so that players start
+// as far from each other as the cave allows and out of each other's sight
+type SpawnPlanner struct {
+	candidates []int        // Indexes into SpawnPoints of the sampled spawn points
+	fields     []*nav.Field // Lengths of the paths from every candidate
+	scores     [][]float64  // How good of a pair two candidates are for enemies, the length of the path between them lowered if they see each other
+	visible    [][]bool     // Whether two candidates can see each other
+	planned    map[*Player]int
+}
+
+// spawnRating ranks a candidate, or a set of them: being hidden from the enemies comes first
+// and the score only decides between candidates which are alike in that
+type spawnRating struct {
+	hidden bool // Out of sight of every enemy and reachable by them
+	score  float64
+}
+
+// better checks whether the rating ranks above the other one
+func (r spawnRating) better(other spawnRating) bool {
+	if r.hidden != other.hidden {
+		return r.hidden
+	}
+	return r.score > other.score
+}
+
+// newSpawnPlanner samples the spawn points of the map of the current round and plans where every player starts it
+func newSpawnPlanner(g *Game) *SpawnPlanner {
+	s := &SpawnPlanner{planned: make(map[*Player]int)}
+	spawns := g.mapData.SpawnPoints
+	if len(spawns) == 0 {
+		return s
+	}
+	grid := g.mapData.navigation()
+
+	walkable := make([]int, 0, len(spawns))
+	for i, spawn := range spawns {
+		if grid.Walkable(spawn.X, spawn.Y) {
+			walkable = append(walkable, i)
+		}
+	}
+	if len(walkable) == 0 {
+		for i := range spawns {
+			walkable = append(walkable, i)
+		}
+	}
+
+	// Farthest point sampling: every next candidate is the spawn point farthest from the ones picked so far
+	s.candidates = []int{walkable[g.rng.Intn(len(walkable))]}
+	nearest := make([]float64, len(walkable))
+	for i := range nearest {
+		nearest[i] = math.Inf(1)
+	}
+	for len(s.candidates) < maxSpawnCandidates && len(s.candidates) < len(walkable) {
+		last := spawns[s.candidates[len(s.candidates)-1]]
+		best := -1
+		for i, index := range walkable {
+			nearest[i] = math.Min(nearest[i], math.Hypot(spawns[index].X-last.X, spawns[index].Y-last.Y))
+			if nearest[i] > 0 && (best == -1 || nearest[i] > nearest[best]) {
+				best = i
+			}
+		}
+		if best == -1 {
+			break // Every spawn point has been picked
+		}
+		s.candidates = append(s.candidates, walkable[best])
+	}
+
+	s.fields = make([]*nav.Field, len(s.candidates))
+	for i, index := range s.candidates {
+		s.fields[i] = grid.Distances(spawns[index].X, spawns[index].Y)
+	}
+	s.scores = make([][]float64, len(s.candidates))
+	s.visible = make([][]bool, len(s.candidates))
+	for i := range s.candidates {
+		s.scores[i] = make([]float64, len(s.candidates))
+		s.visible[i] = make([]bool, len(s.candidates))
+		for j, index := range s.candidates {
+			s.scores[i][j] = s.score(g, i, spawns[index].X, spawns[index].Y)
+			s.visible[i][j] = s.sees(g, i, spawns[index].X, spawns[index].Y)
+		}
+	}
+
+	s.plan(g)
+	return s
+}
+
+// score rates a candidate for a player whose enemy stands at the given point: the length of the path to the enemy,
+// lowered if they can see each other. Places which can't be reached from the candidate score 0, so that players
+// aren't sent into separate pockets of the cave. Candidates in sight of an enemy are only picked if there's no
+// other choice, the lowered score tells apart those
+func (s *SpawnPlanner) score(g *Game, candidate int, xPos, yPos float64) float64 {
+	distance := s.fields[candidate].To(xPos, yPos)
+	if math.IsInf(distance, 1) {
+		return 0
+	}
+	if s.sees(g, candidate, xPos, yPos) {
+		distance *= spawnSightPenalty
+	}
+	return distance
+}
+
+// sees checks whether the candidate and the given point are in sight of each other
+func (s *SpawnPlanner) sees(g *Game, candidate int, xPos, yPos float64) bool {
+	spawn := g.mapData.SpawnPoints[s.candidates[candidate]]
+	return g.mapData.navigation().LineOfSight(spawn.X, spawn.Y, xPos, yPos)
+}
+
+// plan assigns the spawn points of the players at the start of the round: every player for himself in the modes
+// without teams, otherwise every team starts around its own spot and the spots are spread apart
+func (s *SpawnPlanner) plan(g *Game) {
+	if len(s.candidates) == 0 {
+		return
+	}
+	players := g.playersById()
+	if g.teamCount == 0 {
+		picked := s.spread(g, len(players))
+		for i, order := range g.rng.Perm(len(players)) {
+			s.planned[players[order]] = s.candidates[picked[i]]
+		}
+		return
+	}
+
+	teams := make([][]*Player, 0, g.teamCount)
+	for team := 0; team < g.teamCount; team++ {
+		if members := g.teamMembers(team); len(members) > 0 {
+			teams = append(teams, members)
+		}
+	}
+	picked := s.spread(g, len(teams))
+	for i, order := range g.rng.Perm(len(teams)) {
+		s.planTeam(g, teams[order], picked[i])
+	}
+}
+
+// spread picks count candidates maximising the rating of the worst pair among them, preferring candidates out of
+// each other's sight: greedily first, then by swapping picked candidates for better ones. If there are fewer
+// candidates than asked for, they are reused
+func (s *SpawnPlanner) spread(g *Game, count int) []int {
+	if count > len(s.candidates) {
+		picked := make([]int, count)
+		for i := range picked {
+			picked[i] = i % len(s.candidates)
+		}
+		return picked
+	}
+
+	picked := []int{g.rng.Intn(len(s.candidates))}
+	used := map[int]bool{picked[0]: true}
+	for len(picked) < count {
+		best, bestRating := -1, spawnRating{false, -1}
+		for c := range s.candidates {
+			if used[c] {
+				continue
+			}
+			if rating := s.lowestRating(picked, -1, c); rating.better(bestRating) {
+				best, bestRating = c, rating
+			}
+		}
+		picked = append(picked, best)
+		used[best] = true
+	}
+
+	current := s.lowestRating(picked, -1, -1)
+	for pass := 0; pass < spawnPlanPasses; pass++ {
+		improved := false
+		for slot := range picked {
+			for c := range s.candidates {
+				if used[c] {
+					continue
+				}
+				if rating := s.lowestRating(picked, slot, c); rating.better(current) {
+					used[picked[slot]], used[c] = false, true
+					picked[slot], current, improved = c, rating, true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+
+	return picked
+}
+
+// lowestRating rates the worst pair among the picked candidates, with the one in the given slot replaced by candidate
+// (or candidate added if slot is -1, or nothing changed if candidate is -1 too): the set is hidden if no two of them
+// see each other and its score is the lowest score between any two of them
+func (s *SpawnPlanner) lowestRating(picked []int, slot, candidate int) spawnRating {
+	set := append([]int(nil), picked...)
+	if slot >= 0 {
+		set[slot] = candidate
+	} else if candidate >= 0 {
+		set = append(set, candidate)
+	}
+
+	lowest, hidden := math.Inf(1), true
+	for i := range set {
+		for j := i + 1; j < len(set); j++ {
+			lowest = math.Min(lowest, math.Min(s.scores[set[i]][set[j]], s.scores[set[j]][set[i]]))
+			hidden = hidden && !s.visible[set[i]][set[j]]
+		}
+	}
+	return spawnRating{hidden && lowest > 0, lowest}
+}
+
+// planTeam places the members of a team on the spawn points closest to the spot of the team by path,
+// keeping them a little apart as long as there are enough spawn points around
+func (s *SpawnPlanner) planTeam(g *Game, members []*Player, spot int) {
+	spawns := g.mapData.SpawnPoints
+	indexes := make([]int, len(spawns))
+	distances := make([]float64, len(spawns))
+	for i, spawn := range spawns {
+		indexes[i] = i
+		distances[i] = s.fields[spot].To(spawn.X, spawn.Y)
+	}
+	sort.SliceStable(indexes, func(i, j int) bool { return distances[indexes[i]] < distances[indexes[j]] })
+
+	taken := make([]int, 0, len(members))
+	for _, index := range indexes {
+		if len(taken) == len(members) {
+			break
+		}
+		spaced := true
+		for _, other := range taken {
+			if math.Hypot(spawns[index].X-spawns[other].X, spawns[index].Y-spawns[other].Y) < teamSpawnSpacing {
+				spaced = false
+				break
+			}
+		}
+		if spaced {
+			taken = append(taken, index)
+		}
+	}
+	// Not enough room around the spot, the rest of the team shares the spawn points and gets pushed apart
+	for i := len(taken); i < len(members); i++ {
+		taken = append(taken, taken[i%len(taken)])
+	}
+
+	for i, member := range members {
+		s.planned[member] = taken[i]
+	}
+}
+
+// index returns the spawn point of a player: the planned one at the start of the round and, when he comes back
+// during the round, the candidate with the best score against the closest living enemy among those no enemy can see
+func (s *SpawnPlanner) index(g *Game, p *Player) int {
+	if planned, ok := s.planned[p]; ok && g.roundStart.IsZero() {
+		return planned
+	}
+	if len(s.candidates) == 0 {
+		return -1
+	}
+
+	best, bestRating := -1, spawnRating{false, -1}
+	for c := range s.candidates {
+		worst, hidden := math.Inf(1), true
+		for _, other := range g.playersById() {
+			if other == p || !other.alive || g.areTeammates(p, other) {
+				continue
+			}
+			worst = math.Min(worst, s.score(g, c, other.xPos, other.yPos))
+			hidden = hidden && !s.sees(g, c, other.xPos, other.yPos)
+		}
+		if math.IsInf(worst, 1) {
+			return s.candidates[g.rng.Intn(len(s.candidates))] // Nobody to run away from
+		}
+		if rating := (spawnRating{hidden && worst > 0, worst}); rating.better(bestRating) {
+			best, bestRating = c, rating
+		}
+	}
+
+	return s.candidates[best]
+}
+
+// inEnemySight checks whether any living enemy of the player can see the given point
+func (g *Game) inEnemySight(p *Player, xPos, yPos float64) bool {
+	for _, other := range g.playersById() {
+		if other != p && other.alive && !g.areTeammates(p, other) && g.mapData.navigation().LineOfSight(other.xPos, other.yPos, xPos, yPos) {
+			return true
+		}
+	}
+	return false
+}
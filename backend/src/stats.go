@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"projectparty/store"
+)
+
+// PlayerStats counts what a player did over a single round or a whole game, the stats of the game are saved with the match.
+// Shots and hits are only counted while a round is played
+type PlayerStats struct {
+	store.Stats
+
+	streak int // Kills since the last death
+}
+
+// StatsEntry holds the stats of a single player
+type StatsEntry struct {
+	Id    int         `json:"id"`
+	Nick  string      `json:"nick"`
+	Stats PlayerStats `json:"stats"`
+}
+
+// playerStats keeps the stats of a player over the current round and over the whole game
+type playerStats struct {
+	round PlayerStats
+	game  PlayerStats
+}
+
+// update applies the change to the stats of both the round and the game
+func (s *playerStats) update(change func(stats *PlayerStats)) {
+	change(&s.round)
+	change(&s.game)
+}
+
+// updateAccuracy computes the share of the shots fired which hit, never more than all of them
+func (stats *PlayerStats) updateAccuracy() {
+	stats.Accuracy = 0
+	if stats.ShotsFired > 0 {
+		stats.Accuracy = math.Min(float64(stats.Hits)/float64(stats.ShotsFired), 1)
+	}
+}
+
+func (s *playerStats) shotFired() {
+	s.update(func(stats *PlayerStats) {
+		stats.ShotsFired++
+		stats.updateAccuracy()
+	})
+}
+
+// hit counts a shot which struck an enemy after flying the given distance, at most once for every shot
+func (s *playerStats) hit(distance float64) {
+	s.update(func(stats *PlayerStats) {
+		stats.Hits++
+		stats.updateAccuracy()
+		if distance > stats.LongestShot {
+			stats.LongestShot = distance
+		}
+	})
+}
+
+func (s *playerStats) kill() {
+	s.update(func(stats *PlayerStats) {
+		stats.Kills++
+		stats.streak++
+		if stats.streak > stats.KillStreak {
+			stats.KillStreak = stats.streak
+		}
+	})
+}
+
+// teamKill counts a teammate killed with friendly fire, which neither counts as a kill nor extends the streak
+func (s *playerStats) teamKill() {
+	s.update(func(stats *PlayerStats) {
+		stats.TeamKills++
+	})
+}
+
+func (s *playerStats) death() {
+	s.update(func(stats *PlayerStats) {
+		stats.Deaths++
+		stats.streak = 0
+	})
+}
+
+// live counts a step of a round the player spent alive, walking the given distance
+func (s *playerStats) live(step time.Duration, distance float64) {
+	s.update(func(stats *PlayerStats) {
+		stats.TimeAlive += int64(step / time.Millisecond)
+		stats.Distance += distance
+	})
+}
+
+// roundStats returns the stats of every player over the current round, ordered by id
+func (g *Game) roundStats() []StatsEntry {
+	result := make([]StatsEntry, 0, len(g.players))
+	for _, player := range g.playersById() {
+		result = append(result, StatsEntry{player.id, player.nick, player.stats.round})
+	}
+	return result
+}
+
+// gameStats returns the stats of every player over the whole game so far, ordered by id
+func (g *Game) gameStats() []StatsEntry {
+	result := make([]StatsEntry, 0, len(g.players))
+	for _, player := range g.playersById() {
+		result = append(result, StatsEntry{player.id, player.nick, player.stats.game})
+	}
+	return result
+}
+
+// resetRoundStats starts counting the stats of a new round
+func (g *Game) resetRoundStats() {
+	for _, player := range g.players {
+		player.stats.round = PlayerStats{}
+	}
+}
+
+// sendStats sends the stats summary of the round or the game to the host and to every controller
+//		Stats::round::json
+//		Stats::game::json
+// json being an array of StatsEntry ordered by player id
+func (g *Game) sendStats(scope string, stats []StatsEntry) {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		fmt.Fprintln(g.logger, "Encoding the stats failed with error ", err)
+		return
+	}
+	g.broadcast([]byte(fmt.Sprintf("Stats::%s::%s", scope, data)))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+
+	"projectparty/store"
+)
+
+// storePath holds the file the match history and the player profiles are kept in, they are only kept in memory if it's empty
+var storePath = flag.String("store", "store.log", "file keeping the match history and the player profiles, empty to keep them in memory only")
+
+// storage keeps everything that outlives a game, the server replaces it with the file store on start
+var storage store.Store = store.NewMemoryStore()
+
+// openStorage replaces the in memory store with the file at storePath
+func openStorage() error {
+	if *storePath == "" {
+		return nil
+	}
+
+	fileStore, err := store.OpenFileStore(*storePath)
+	if err != nil {
+		return err
+	}
+	storage = fileStore
+	return nil
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// maxRecordSize is the longest record the log can be read back with
+const maxRecordSize = 16 * 1024 * 1024
+
+// record is a single line of the log, data being encoded as JSON according to the kind:
+//		match - Match
+//		profile - Profile, the latest version of a profile replacing the earlier ones
+//		rating - Rating, the latest version of a rating replacing the earlier ones
+//		ratingChange - RatingChange
+type record struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+// FileStore keeps everything in an append-only log file with one record in JSON per line,
+// which is read back into memory when the store is opened. Changes reach memory only once they are in the log,
+// so that nothing is served which would be lost by a restart. It's safe for use by several goroutines at once
+type FileStore struct {
+	memory *MemoryStore // Everything in the log, answers the queries
+	lock   sync.Mutex   // Keeps the order of the records in the log the same as in memory
+	file   *os.File
+}
+
+// OpenFileStore opens the log at the given path, creating it if it doesn't exist. A last record cut short by a crash
+// is dropped, while a damaged record anywhere else fails the opening and the log is left as it is
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{memory: NewMemoryStore(), file: file}
+	valid, err := s.load()
+	if err == nil {
+		err = file.Truncate(valid)
+	}
+	if err == nil {
+		_, err = file.Seek(valid, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the log into memory and returns the length of the part of it made of complete records
+func (s *FileStore) load() (int64, error) {
+	var valid int64
+	reader := bufio.NewReaderSize(s.file, 64*1024)
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return valid, nil // A last line without a newline didn't make it to the disk whole
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if len(line) > maxRecordSize {
+			return 0, fmt.Errorf("record %d of the log is longer than %d bytes", number, maxRecordSize)
+		}
+		var r record
+		if err := json.Unmarshal(bytes.TrimSpace(line), &r); err != nil {
+			return 0, fmt.Errorf("record %d of the log is damaged: %v", number, err)
+		}
+		if err := s.apply(r); err != nil {
+			return 0, fmt.Errorf("record %d of the log can't be read back: %v", number, err)
+		}
+		valid += int64(len(line))
+	}
+}
+
+// apply puts a record read from the log into memory
+func (s *FileStore) apply(r record) error {
+	switch r.Kind {
+	case "match":
+		var match Match
+		if err := json.Unmarshal(r.Data, &match); err != nil {
+			return err
+		}
+		return s.memory.SaveMatch(&match)
+	case "profile":
+		var profile Profile
+		if err := json.Unmarshal(r.Data, &profile); err != nil {
+			return err
+		}
+		return s.memory.restoreProfile(profile)
+	case "rating":
+		var rating Rating
+		if err := json.Unmarshal(r.Data, &rating); err != nil {
+			return err
+		}
+		return s.memory.SaveRatings([]Rating{rating}, nil)
+	case "ratingChange":
+		var change RatingChange
+		if err := json.Unmarshal(r.Data, &change); err != nil {
+			return err
+		}
+		return s.memory.SaveRatings(nil, []RatingChange{change})
+	default:
+		return fmt.Errorf("unknown record %s", r.Kind)
+	}
+}
+
+// newRecord encodes the data of a record of the given kind
+func newRecord(kind string, data interface{}) (record, error) {
+	raw, err := json.Marshal(data)
+	return record{kind, raw}, err
+}
+
+// append writes the records to the end of the log and makes sure they reach the disk. If that fails,
+// the log is cut back to where it ended before, so that it doesn't hold a change which didn't make it to memory
+func (s *FileStore) append(records ...record) error {
+	end, err := s.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if err = s.write(r); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		s.file.Truncate(end)
+		s.file.Seek(end, io.SeekStart)
+	}
+	return err
+}
+
+// write writes a record to the end of the log without waiting for it to reach the disk
+func (s *FileStore) write(r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileStore) SaveMatch(match *Match) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	saved := *match
+	saved.Id = s.memory.nextMatchId()
+	r, err := newRecord("match", saved)
+	if err == nil {
+		err = s.append(r)
+	}
+	if err != nil {
+		return err
+	}
+	return s.memory.SaveMatch(match)
+}
+
+func (s *FileStore) Match(id uint64) (Match, error) {
+	return s.memory.Match(id)
+}
+
+func (s *FileStore) RecentMatches(limit int) ([]Match, error) {
+	return s.memory.RecentMatches(limit)
+}
+
+func (s *FileStore) CreateProfile(profile *Profile) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	id, err := s.memory.nextProfileId(profile.Token)
+	if err != nil {
+		return err
+	}
+	created := *profile
+	created.Id = id
+	r, err := newRecord("profile", created)
+	if err == nil {
+		err = s.append(r)
+	}
+	if err != nil {
+		return err
+	}
+	return s.memory.CreateProfile(profile)
+}
+
+func (s *FileStore) UpdateProfile(id uint64, update func(profile *Profile)) (Profile, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	profile, err := s.memory.Profile(id)
+	if err != nil {
+		return profile, err
+	}
+	token := profile.Token
+	update(&profile)
+	profile.Id, profile.Token = id, token // Neither the id nor the token can be changed
+	r, err := newRecord("profile", profile)
+	if err == nil {
+		err = s.append(r)
+	}
+	if err != nil {
+		return Profile{}, err
+	}
+	return profile, s.memory.restoreProfile(profile)
+}
+
+func (s *FileStore) Profile(id uint64) (Profile, error) {
+	return s.memory.Profile(id)
+}
+
+func (s *FileStore) ProfileByToken(token string) (Profile, error) {
+	return s.memory.ProfileByToken(token)
+}
+
+func (s *FileStore) Rating(profileId uint64, mode string) (Rating, error) {
+	return s.memory.Rating(profileId, mode)
+}
+
+func (s *FileStore) Ratings(profileId uint64) ([]Rating, error) {
+	return s.memory.Ratings(profileId)
+}
+
+func (s *FileStore) SaveRatings(ratings []Rating, changes []RatingChange) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	records := make([]record, 0, len(ratings)+len(changes))
+	for _, rating := range ratings {
+		r, err := newRecord("rating", rating)
+		if err != nil {
+			return err
+		}
+		records = append(records, r)
+	}
+	for _, change := range changes {
+		r, err := newRecord("ratingChange", change)
+		if err != nil {
+			return err
+		}
+		records = append(records, r)
+	}
+	if err := s.append(records...); err != nil {
+		return err
+	}
+	return s.memory.SaveRatings(ratings, changes)
+}
+
+func (s *FileStore) Leaderboard(mode string, limit int) ([]Rating, error) {
+	return s.memory.Leaderboard(mode, limit)
+}
+
+func (s *FileStore) RatingHistory(profileId uint64, limit int) ([]RatingChange, error) {
+	return s.memory.RatingHistory(profileId, limit)
+}
+
+func (s *FileStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Close()
+}
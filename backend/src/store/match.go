@@ -0,0 +1,81 @@
+package store
+
+import "time"
+
+// Match is a completed game
+type Match struct {
+	Id        uint64        `json:"id"` // Assigned by the store once the match is saved, starting from 1
+	GameId    uint64        `json:"gameId"`
+	Seed      int64         `json:"seed"`
+	Mode      string        `json:"mode"` // Params of the Mode command
+	Started   time.Time     `json:"started"`
+	Ended     time.Time     `json:"ended"`
+	Players   []Participant `json:"players"`
+	Rounds    []Round       `json:"rounds"`
+	Kills     []Kill        `json:"kills"`
+	Standings []Standing    `json:"standings"` // Ordered from the first place
+}
+
+// Duration returns how long the match took
+func (m *Match) Duration() time.Duration {
+	return m.Ended.Sub(m.Started)
+}
+
+// Participant is a player of a match, told apart from the others by his id within the match
+type Participant struct {
+	Id   int    `json:"id"`
+	Nick string `json:"nick"`
+	Team int    `json:"team"` // -1 in the modes without teams
+
+	ProfileId uint64 `json:"profileId,omitempty"` // Profile the player joined with, 0 if he played anonymously
+	Stats     Stats  `json:"stats"`               // What the player did over the whole match
+}
+
+// Stats counts what a player did over a match
+type Stats struct {
+	ShotsFired  int     `json:"shotsFired"`
+	Hits        int     `json:"hits"`     // Shots which struck an enemy, including the ones stopped by spawn protection
+	Accuracy    float64 `json:"accuracy"` // Share of the shots fired which hit, 0 if none were fired
+	Kills       int     `json:"kills"`    // Enemies killed, teammates killed with friendly fire are only counted in TeamKills
+	TeamKills   int     `json:"teamKills"`
+	Deaths      int     `json:"deaths"`
+	KillStreak  int     `json:"killStreak"`  // Most kills in a row without dying
+	LongestShot float64 `json:"longestShot"` // Longest distance between where a shot was fired from and the player it hit
+	TimeAlive   int64   `json:"timeAlive"`   // Milliseconds spent alive while the rounds were played
+	Distance    float64 `json:"distance"`    // Distance walked, not counting being pushed or respawning
+}
+
+// Round is the result of a single round of a match
+type Round struct {
+	Number   int           `json:"number"`  // Starting from 1
+	MapSeed  int32         `json:"mapSeed"` // Seed the map of the round was generated with
+	Winner   int           `json:"winner"`  // Id of the winning player, -1 if none
+	Team     int           `json:"team"`    // Winning team, -1 in the modes without teams or if none
+	Duration time.Duration `json:"duration"`
+}
+
+// Kill is a player shot by another one
+type Kill struct {
+	Round   int    `json:"round"`
+	Tick    uint64 `json:"tick"` // Step of the simulation the kill happened at
+	Shooter int    `json:"shooter"`
+	Target  int    `json:"target"`
+}
+
+// Standing is the final result of a player or a whole team, entries which tied share the place
+type Standing struct {
+	Place   int   `json:"place"`
+	Score   int   `json:"score"`
+	Team    int   `json:"team"` // -1 unless the entry belongs to a team
+	Players []int `json:"players"`
+}
+
+// MatchStore keeps the history of the completed matches
+type MatchStore interface {
+	// SaveMatch stores a completed match, setting its id
+	SaveMatch(match *Match) error
+	// Match returns the match with the given id or ErrNotFound
+	Match(id uint64) (Match, error)
+	// RecentMatches returns at most limit matches, the latest first
+	RecentMatches(limit int) ([]Match, error)
+}
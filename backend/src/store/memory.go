@@ -0,0 +1,202 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps everything in memory, it's safe for use by several goroutines at once
+type MemoryStore struct {
+	lock     sync.Mutex
+	matches  []Match   // Ordered by id
+	profiles []Profile // Ordered by id
+	tokens   map[string]uint64
+	ratings  map[ratingKey]Rating
+	changes  []RatingChange // Ordered from the oldest
+}
+
+// ratingKey tells apart the ratings of a profile in different modes
+type ratingKey struct {
+	profileId uint64
+	mode      string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{matches: make([]Match, 0), profiles: make([]Profile, 0), tokens: make(map[string]uint64), ratings: make(map[ratingKey]Rating)}
+}
+
+func (s *MemoryStore) SaveMatch(match *Match) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	match.Id = uint64(len(s.matches)) + 1
+	s.matches = append(s.matches, *match)
+	return nil
+}
+
+func (s *MemoryStore) Match(id uint64) (Match, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if id == 0 || id > uint64(len(s.matches)) {
+		return Match{}, ErrNotFound
+	}
+	return s.matches[id-1], nil
+}
+
+func (s *MemoryStore) RecentMatches(limit int) ([]Match, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make([]Match, 0, limit)
+	for i := len(s.matches) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, s.matches[i])
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) CreateProfile(profile *Profile) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.tokens[profile.Token]; ok {
+		return ErrExists
+	}
+	profile.Id = uint64(len(s.profiles)) + 1
+	s.profiles = append(s.profiles, *profile)
+	s.tokens[profile.Token] = profile.Id
+	return nil
+}
+
+func (s *MemoryStore) UpdateProfile(id uint64, update func(profile *Profile)) (Profile, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if id == 0 || id > uint64(len(s.profiles)) {
+		return Profile{}, ErrNotFound
+	}
+
+	profile := &s.profiles[id-1]
+	token := profile.Token
+	update(profile)
+	profile.Id, profile.Token = id, token // Neither the id nor the token can be changed
+	return *profile, nil
+}
+
+func (s *MemoryStore) Profile(id uint64) (Profile, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if id == 0 || id > uint64(len(s.profiles)) {
+		return Profile{}, ErrNotFound
+	}
+	return s.profiles[id-1], nil
+}
+
+func (s *MemoryStore) ProfileByToken(token string) (Profile, error) {
+	s.lock.Lock()
+	id, ok := s.tokens[token]
+	s.lock.Unlock()
+	if !ok {
+		return Profile{}, ErrNotFound
+	}
+	return s.Profile(id)
+}
+
+// nextMatchId returns the id the next saved match gets
+func (s *MemoryStore) nextMatchId() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return uint64(len(s.matches)) + 1
+}
+
+// nextProfileId returns the id a profile with the given token gets once it's created, or ErrExists if the token is taken
+func (s *MemoryStore) nextProfileId(token string) (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.tokens[token]; ok {
+		return 0, ErrExists
+	}
+	return uint64(len(s.profiles)) + 1, nil
+}
+
+// restoreProfile puts a profile read back from a log into the store, replacing an older version of it
+func (s *MemoryStore) restoreProfile(profile Profile) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch {
+	case profile.Id == uint64(len(s.profiles))+1:
+		s.profiles = append(s.profiles, profile)
+	case profile.Id > 0 && profile.Id <= uint64(len(s.profiles)):
+		s.profiles[profile.Id-1] = profile
+	default:
+		return fmt.Errorf("profile %d is out of order", profile.Id)
+	}
+	s.tokens[profile.Token] = profile.Id
+	return nil
+}
+
+func (s *MemoryStore) Rating(profileId uint64, mode string) (Rating, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rating, ok := s.ratings[ratingKey{profileId, mode}]
+	if !ok {
+		return Rating{}, ErrNotFound
+	}
+	return rating, nil
+}
+
+func (s *MemoryStore) Ratings(profileId uint64) ([]Rating, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make([]Rating, 0)
+	for key, rating := range s.ratings {
+		if key.profileId == profileId {
+			result = append(result, rating)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Mode < result[j].Mode })
+	return result, nil
+}
+
+func (s *MemoryStore) SaveRatings(ratings []Rating, changes []RatingChange) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, rating := range ratings {
+		s.ratings[ratingKey{rating.ProfileId, rating.Mode}] = rating
+	}
+	s.changes = append(s.changes, changes...)
+	return nil
+}
+
+func (s *MemoryStore) Leaderboard(mode string, limit int) ([]Rating, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make([]Rating, 0)
+	for key, rating := range s.ratings {
+		if key.mode == mode {
+			result = append(result, rating)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Rating != result[j].Rating {
+			return result[i].Rating > result[j].Rating
+		}
+		return result[i].ProfileId < result[j].ProfileId
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) RatingHistory(profileId uint64, limit int) ([]RatingChange, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make([]RatingChange, 0, limit)
+	for i := len(s.changes) - 1; i >= 0 && len(result) < limit; i-- {
+		if s.changes[i].ProfileId == profileId {
+			result = append(result, s.changes[i])
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrExists is returned when data which has to be unique is stored twice
+var ErrExists = errors.New("already exists")
+
+// Profile is the identity a player keeps across games, known to his controller by its device token
+type Profile struct {
+	Id       uint64    `json:"id"`    // Assigned by the store once the profile is created, starting from 1
+	Token    string    `json:"token"` // Secret issued to the controller, it must never be sent to anybody else
+	Name     string    `json:"name"`
+	Colour   string    `json:"colour"` // Hex RGB like "ff8800"
+	Avatar   int       `json:"avatar"`
+	Created  time.Time `json:"created"`
+	LastSeen time.Time `json:"lastSeen"`
+	Games    int       `json:"games"` // Completed matches played
+	Wins     int       `json:"wins"`  // Completed matches finished in the first place
+}
+
+// ProfileStore keeps the profiles of the players
+type ProfileStore interface {
+	// CreateProfile stores a new profile, setting its id, or returns ErrExists if its token is taken
+	CreateProfile(profile *Profile) error
+	// UpdateProfile changes the profile with the given id with update and returns the result, or ErrNotFound.
+	// Updates of a single profile never overlap, so update can read and change it safely
+	UpdateProfile(id uint64, update func(profile *Profile)) (Profile, error)
+	// Profile returns the profile with the given id or ErrNotFound
+	Profile(id uint64) (Profile, error)
+	// ProfileByToken returns the profile issued the given token or ErrNotFound
+	ProfileByToken(token string) (Profile, error)
+}
@@ -0,0 +1,39 @@
+package store
+
+import "time"
+
+// Rating is the skill of a player estimated from his results, Glicko style: the rating itself and how uncertain it is
+type Rating struct {
+	ProfileId uint64    `json:"profileId"`
+	Mode      string    `json:"mode"` // Game mode the rating is for, empty for the global rating over every mode
+	Rating    float64   `json:"rating"`
+	Deviation float64   `json:"deviation"` // The lower, the more certain the rating is
+	Games     int       `json:"games"`     // Rated matches played
+	Updated   time.Time `json:"updated"`
+}
+
+// RatingChange is an entry in the rating history of a player, one per rated match and rating
+type RatingChange struct {
+	ProfileId uint64    `json:"profileId"`
+	Mode      string    `json:"mode"` // Empty for the global rating
+	Match     uint64    `json:"match"`
+	Place     int       `json:"place"`
+	Before    float64   `json:"before"`
+	After     float64   `json:"after"`
+	Deviation float64   `json:"deviation"` // Deviation after the match
+	Time      time.Time `json:"time"`
+}
+
+// RatingStore keeps the ratings of the players and their history
+type RatingStore interface {
+	// Rating returns the rating of a profile in a mode, the global one if mode is empty, or ErrNotFound
+	Rating(profileId uint64, mode string) (Rating, error)
+	// Ratings returns every rating of a profile, the global one first and the rest ordered by mode
+	Ratings(profileId uint64) ([]Rating, error)
+	// SaveRatings stores the ratings updated after a match together with the changes made to them
+	SaveRatings(ratings []Rating, changes []RatingChange) error
+	// Leaderboard returns at most limit ratings in a mode, empty for the global ones, the highest first
+	Leaderboard(mode string, limit int) ([]Rating, error)
+	// RatingHistory returns at most limit changes of the ratings of a profile, the latest first
+	RatingHistory(profileId uint64, limit int) ([]RatingChange, error)
+}
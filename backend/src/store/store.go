@@ -0,0 +1,21 @@
+// Package store keeps what outlives a single game: the history of the matches played on the server,
+// the profiles of the players and their ratings.
+// Every kind of data is described by an interface, implemented by MemoryStore, which forgets everything
+// once the server stops and is meant for tests and headless runs, and by FileStore, which keeps it in a local log.
+package store
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when the requested data doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// Store is everything the server persists
+type Store interface {
+	MatchStore
+	ProfileStore
+	RatingStore
+	io.Closer
+}
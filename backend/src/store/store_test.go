@@ -0,0 +1,263 @@
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	started = time.Date(2020, 5, 1, 18, 0, 0, 0, time.UTC)
+	ended   = started.Add(7 * time.Minute)
+)
+
+// fill stores two matches, two profiles and the ratings of one of them
+func fill(t *testing.T, s Store) {
+	for _, match := range []*Match{
+		{GameId: 3, Seed: 42, Mode: "ffa", Started: started, Ended: ended,
+			Players:   []Participant{{Id: 0, Nick: "a", Team: -1, ProfileId: 1, Stats: Stats{ShotsFired: 8, Hits: 2, Accuracy: 0.25, Kills: 1}}, {Id: 1, Nick: "b", Team: -1}},
+			Rounds:    []Round{{Number: 1, MapSeed: 7, Winner: 0, Team: -1, Duration: 40 * time.Second}},
+			Kills:     []Kill{{Round: 1, Tick: 120, Shooter: 0, Target: 1}},
+			Standings: []Standing{{Place: 1, Score: 5, Team: -1, Players: []int{0}}, {Place: 2, Score: 0, Team: -1, Players: []int{1}}}},
+		{GameId: 4, Seed: 43, Mode: "teams/2/0", Started: ended, Ended: ended.Add(time.Minute)},
+	} {
+		if err := s.SaveMatch(match); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, profile := range []*Profile{
+		{Token: "first", Name: "a", Colour: "ff8800", Avatar: 2, Created: started, LastSeen: started},
+		{Token: "second", Name: "b", Created: started, LastSeen: started},
+	} {
+		if err := s.CreateProfile(profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.CreateProfile(&Profile{Token: "first", Name: "c"}); err != ErrExists {
+		t.Errorf("creating a profile with a taken token returned %v, want ErrExists", err)
+	}
+	if _, err := s.UpdateProfile(1, func(profile *Profile) { profile.Games, profile.Wins = 1, 1 }); err != nil {
+		t.Fatal(err)
+	}
+
+	ratings := []Rating{
+		{ProfileId: 1, Mode: "", Rating: 1662, Deviation: 290, Games: 1, Updated: ended},
+		{ProfileId: 1, Mode: "ffa", Rating: 1662, Deviation: 290, Games: 1, Updated: ended},
+		{ProfileId: 2, Mode: "", Rating: 1338, Deviation: 290, Games: 1, Updated: ended},
+	}
+	changes := []RatingChange{
+		{ProfileId: 1, Mode: "", Match: 1, Place: 1, Before: 1500, After: 1662, Deviation: 290, Time: ended},
+		{ProfileId: 2, Mode: "", Match: 1, Place: 2, Before: 1500, After: 1338, Deviation: 290, Time: ended},
+	}
+	if err := s.SaveRatings(ratings, changes); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// check verifies that the store holds what fill stored
+func check(t *testing.T, s Store) {
+	match, err := s.Match(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match.Id != 1 || match.Seed != 42 || match.Duration() != 7*time.Minute || len(match.Kills) != 1 || match.Players[0].ProfileId != 1 || match.Players[0].Stats.Hits != 2 {
+		t.Errorf("match 1 = %+v", match)
+	}
+	if _, err := s.Match(3); err != ErrNotFound {
+		t.Errorf("missing match returned %v, want ErrNotFound", err)
+	}
+	if recent, err := s.RecentMatches(1); err != nil || len(recent) != 1 || recent[0].Id != 2 {
+		t.Errorf("recent matches = %+v, %v, want match 2", recent, err)
+	}
+
+	want := Profile{Id: 1, Token: "first", Name: "a", Colour: "ff8800", Avatar: 2, Created: started, LastSeen: started, Games: 1, Wins: 1}
+	if profile, err := s.Profile(1); err != nil || !reflect.DeepEqual(profile, want) {
+		t.Errorf("profile 1 = %+v, %v, want %+v", profile, err, want)
+	}
+	if profile, err := s.ProfileByToken("second"); err != nil || profile.Id != 2 {
+		t.Errorf("profile of token second = %+v, %v, want profile 2", profile, err)
+	}
+	if _, err := s.ProfileByToken("third"); err != ErrNotFound {
+		t.Errorf("unknown token returned %v, want ErrNotFound", err)
+	}
+
+	if rating, err := s.Rating(1, "ffa"); err != nil || rating.Rating != 1662 || !rating.Updated.Equal(ended) {
+		t.Errorf("ffa rating of profile 1 = %+v, %v", rating, err)
+	}
+	if ratings, err := s.Ratings(1); err != nil || len(ratings) != 2 || ratings[0].Mode != "" {
+		t.Errorf("ratings of profile 1 = %+v, %v, want the global one first", ratings, err)
+	}
+	if leaderboard, err := s.Leaderboard("", 10); err != nil || len(leaderboard) != 2 || leaderboard[0].ProfileId != 1 {
+		t.Errorf("leaderboard = %+v, %v, want profile 1 first", leaderboard, err)
+	}
+	if history, err := s.RatingHistory(2, 10); err != nil || len(history) != 1 || history[0].After != 1338 {
+		t.Errorf("rating history of profile 2 = %+v, %v", history, err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name   string
+		open   func() (Store, error)
+		reopen func(s Store) (Store, error) // Returns the store as it would be after a restart of the server
+	}{
+		{
+			"memory",
+			func() (Store, error) { return NewMemoryStore(), nil },
+			func(s Store) (Store, error) { return s, nil },
+		},
+		{
+			"file",
+			func() (Store, error) { return OpenFileStore(filepath.Join(dir, "store.log")) },
+			func(s Store) (Store, error) {
+				if err := s.Close(); err != nil {
+					return nil, err
+				}
+				return OpenFileStore(filepath.Join(dir, "store.log"))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := test.open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fill(t, s)
+			check(t, s)
+
+			if s, err = test.reopen(s); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			check(t, s)
+		})
+	}
+}
+
+func TestFileStoreDropsPartialRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "store.log")
+
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, s)
+	s.Close()
+
+	// A crash in the middle of writing a record leaves it cut short
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"kind":"match","data":{"gameId":5,`)
+	file.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, s)
+	if err := s.SaveMatch(&Match{GameId: 5}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if match, err := s.Match(3); err != nil || match.GameId != 5 {
+		t.Errorf("match saved after the partial record = %+v, %v", match, err)
+	}
+}
+
+func TestFileStoreKeepsDamagedLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "store.log")
+
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, s)
+	s.Close()
+
+	// A complete line which isn't a record can't be a write cut short, so the log is left for a human to look at
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("{\"kind\":\"match\",\n")
+	file.WriteString("{\"kind\":\"profile\",\"data\":{\"id\":7}}\n")
+	file.Close()
+	before, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenFileStore(path); err == nil {
+		t.Fatal("opening a damaged log succeeded")
+	}
+	if after, err := ioutil.ReadFile(path); err != nil || !bytes.Equal(after, before) {
+		t.Errorf("the damaged log was changed to %q, %v", after, err)
+	}
+}
+
+func TestFileStoreFailedWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := OpenFileStore(filepath.Join(dir, "store.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, s)
+
+	// Nothing can be written once the file is gone, so nothing may show up in memory either
+	s.file.Close()
+	if err := s.SaveMatch(&Match{GameId: 5}); err == nil {
+		t.Error("saving a match without the log succeeded")
+	}
+	if err := s.CreateProfile(&Profile{Token: "third"}); err == nil {
+		t.Error("creating a profile without the log succeeded")
+	}
+	if _, err := s.UpdateProfile(1, func(profile *Profile) { profile.Name = "d" }); err == nil {
+		t.Error("updating a profile without the log succeeded")
+	}
+	if err := s.SaveRatings([]Rating{{ProfileId: 2, Mode: "ffa", Rating: 1400}}, nil); err == nil {
+		t.Error("saving ratings without the log succeeded")
+	}
+	check(t, s)
+	if _, err := s.ProfileByToken("third"); err != ErrNotFound {
+		t.Errorf("profile which wasn't written returned %v, want ErrNotFound", err)
+	}
+	if _, err := s.Rating(2, "ffa"); err != ErrNotFound {
+		t.Errorf("rating which wasn't written returned %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// teamDeathmatch is played like the default mode, except that the last team standing wins the round
+type teamDeathmatch struct {
+	lastManStanding
+	teamCount    int
+	friendlyFire bool
+}
+
+// newTeamDeathmatch creates the mode from the "$teamCount/$friendlyFire" params
+func newTeamDeathmatch(params []string) (GameMode, error) {
+	if len(params) != 2 {
+		return nil, errors.New("teams mode requires a team count and a friendly fire flag")
+	}
+	teamCount, err := strconv.Atoi(params[0])
+	if err != nil || teamCount < minTeamCount || teamCount > maxTeamCount {
+		return nil, fmt.Errorf("team count must be between %d and %d", minTeamCount, maxTeamCount)
+	}
+
+	return &teamDeathmatch{teamCount: teamCount, friendlyFire: params[1] == "1"}, nil
+}
+
+func (m *teamDeathmatch) teams() (int, bool) {
+	return m.teamCount, m.friendlyFire
+}
+
+func (m *teamDeathmatch) spawnIndex(g *Game, p *Player) int {
+	return g.spawns.index(g, p)
+}
+
+// onHit awards the shooter and his team, killing a teammate costs a point and earns nothing for the team
+func (m *teamDeathmatch) onHit(g *Game, shooter, target *Player) {
+	if g.areTeammates(shooter, target) {
+		shooter.score--
+		return
+	}
+
+	shooter.score++
+	shooter.kills++
+	g.teamScores[shooter.team]++
+}
+
+func (m *teamDeathmatch) isRoundOver(g *Game) bool {
+	_, over := g.checkTeamRoundEnd()
+	return over
+}
+
+func (m *teamDeathmatch) endRound(g *Game) {
+	team, _ := g.checkTeamRoundEnd()
+	g.endTeamRound(team)
+}
+
+func (m *teamDeathmatch) finalStandings(g *Game) []Standing {
+	return g.teamStandings()
+}
+
+// assignTeams puts every player in a team, keeping the choices made on the controllers and balancing the rest
+func (g *Game) assignTeams() {
+	players := g.playersById()
+	for _, player := range players {
+		player.team = noTeam
+	}
+	if g.teamCount == 0 {
+		return
+	}
+
+	for _, player := range players {
+		if player.preferredTeam >= 0 && player.preferredTeam < g.teamCount {
+			player.team = player.preferredTeam
+		}
+	}
+	for _, player := range players {
+		if player.team == noTeam {
+			player.team = g.smallestTeam()
+		}
+	}
+}
+
+// joinTeam puts a newly registered player in his preferred team, or in the smallest one if he has no valid preference
+func (g *Game) joinTeam(player *Player) {
+	if g.teamCount == 0 {
+		player.team = noTeam
+		return
+	}
+
+	if player.preferredTeam >= 0 && player.preferredTeam < g.teamCount {
+		player.team = player.preferredTeam
+	} else {
+		player.team = g.smallestTeam()
+	}
+}
+
+// smallestTeam returns the team with the fewest members, the lowest index wins ties
+func (g *Game) smallestTeam() int {
+	sizes := make([]int, g.teamCount)
+	for _, player := range g.players {
+		if player.team >= 0 && player.team < g.teamCount {
+			sizes[player.team]++
+		}
+	}
+
+	smallest := 0
+	for team := range sizes {
+		if sizes[team] < sizes[smallest] {
+			smallest = team
+		}
+	}
+
+	return smallest
+}
+
+// teamMembers returns the players of the given team ordered by their ids
+func (g *Game) teamMembers(team int) []*Player {
+	result := make([]*Player, 0)
+	for _, player := range g.playersById() {
+		if player.team == team {
+			result = append(result, player)
+		}
+	}
+
+	return result
+}
+
+// playersById returns all the players ordered by their ids
+func (g *Game) playersById() []*Player {
+	result := make([]*Player, 0, len(g.players))
+	for _, player := range g.players {
+		result = append(result, player)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+
+	return result
+}
+
+// areTeammates checks whether two different players play in the same team
+func (g *Game) areTeammates(a, b *Player) bool {
+	return g.teamCount > 0 && a != b && a.team == b.team
+}
+
+// getTeamsUpdate creates a message with the team of every player
+// Correct message format (entries separated with a comma):
+// 		Teams::id/team
+func (g *Game) getTeamsUpdate() []byte {
+	entries := make([]string, 0, len(g.players))
+	for _, player := range g.playersById() {
+		entries = append(entries, fmt.Sprintf("%d/%d", player.id, player.team))
+	}
+
+	return []byte("Teams::" + strings.Join(entries, ","))
+}
+
+// getTeamScores creates the team part of the scoreboard update
+// Correct message format (entries separated with a comma):
+// 		team/score
+func (g *Game) getTeamScores() string {
+	entries := make([]string, 0, len(g.teamScores))
+	for team, score := range g.teamScores {
+		entries = append(entries, fmt.Sprintf("%d/%d", team, score))
+	}
+
+	return strings.Join(entries, ",")
+}
+
+// checkTeamRoundEnd reports whether only one team (or nobody) is left alive and returns the winning team, noTeam on a draw
+func (g *Game) checkTeamRoundEnd() (int, bool) {
+	winner := noTeam
+	for _, player := range g.players {
+		if !player.alive {
+			continue
+		}
+		if winner == noTeam {
+			winner = player.team
+		} else if winner != player.team {
+			return noTeam, false
+		}
+	}
+
+	return winner, true
+}
+
+// endTeamRound awards the team which survived the round and announces it along with its surviving member with the lowest id
+// Correct message format:
+// 		EndRound::id::team, both being drawMarker if nobody survived
+func (g *Game) endTeamRound(team int) {
+	if team == noTeam {
+		fmt.Fprintln(g.logger, "Sending info about end of team round with a draw")
+		g.sendEndRound(drawMarker, drawMarker)
+		return
+	}
+
+	g.teamScores[team] += g.settings.Prize
+	g.teamRoundsWon[team]++
+	survivor := drawMarker
+	for _, player := range g.teamMembers(team) {
+		if player.alive {
+			player.roundsWon++
+			if survivor == drawMarker {
+				survivor = player.id
+			}
+		}
+	}
+
+	fmt.Fprintln(g.logger, "Sending info about end of round won by team ", team)
+	g.sendInfo(g.getScoreBoardUpdate())
+	g.sendEndRound(survivor, team)
+}
+
+// teamStandings orders the teams by score, teams sharing a score are told apart by rounds won
+// and then by kills, if that still doesn't settle it they share the place
+func (g *Game) teamStandings() []Standing {
+	kills := make([]int, g.teamCount)
+	for _, player := range g.players {
+		kills[player.team] += player.kills
+	}
+	compare := func(a, b int) int {
+		if g.teamScores[a] != g.teamScores[b] {
+			return g.teamScores[a] - g.teamScores[b]
+		}
+		if g.teamRoundsWon[a] != g.teamRoundsWon[b] {
+			return g.teamRoundsWon[a] - g.teamRoundsWon[b]
+		}
+		return kills[a] - kills[b]
+	}
+
+	teams := make([]int, g.teamCount)
+	for team := range teams {
+		teams[team] = team
+	}
+	sort.SliceStable(teams, func(i, j int) bool { return compare(teams[i], teams[j]) > 0 })
+
+	result := make([]Standing, 0, len(teams))
+	for i, team := range teams {
+		place := i + 1
+		if i > 0 && compare(teams[i-1], team) == 0 {
+			place = result[i-1].place
+		}
+		result = append(result, Standing{place, g.teamScores[team], team, g.teamMembers(team)})
+	}
+
+	return result
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SafeZone is the area players have to stay in during sudden death, it shrinks towards its centre over time
+type SafeZone struct {
+	xPos        float64
+	yPos        float64
+	startRadius float64
+	radius      float64
+	started     time.Time
+}
+
+// NewSafeZone returns a zone centred in (xPos, yPos) which initially covers the whole map and starts shrinking at started
+func NewSafeZone(xPos, yPos float64, started time.Time) *SafeZone {
+	startRadius := 0.0
+	for _, corner := range [][2]float64{{0, 0}, {0, 1}, {1, 0}, {1, 1}} {
+		startRadius = math.Max(startRadius, math.Hypot(corner[0]-xPos, corner[1]-yPos))
+	}
+
+	return &SafeZone{xPos, yPos, startRadius, startRadius, started}
+}
+
+// shrink updates the radius of the zone at the time now, reaching zero after zoneShrinkTime
+func (z *SafeZone) shrink(now time.Time) {
+	progress := float64(now.Sub(z.started)) / float64(zoneShrinkTime)
+	z.radius = math.Max(z.startRadius*(1-progress), 0)
+}
+
+// contains checks whether the point (xPos, yPos) lies inside of the zone
+func (z *SafeZone) contains(xPos, yPos float64) bool {
+	return math.Hypot(xPos-z.xPos, yPos-z.yPos) <= z.radius
+}
+
+// String returns the zone in the format sent to the screen: xPos/yPos/radius
+func (z *SafeZone) String() string {
+	return fmt.Sprintf("%f/%f/%f", z.xPos, z.yPos, z.radius)
+}
+
+// updateZone starts sudden death once the round time limit passes and damages the players left outside of the zone
+func (m *lastManStanding) updateZone(g *Game) {
+	if g.roundStart.IsZero() {
+		return
+	}
+
+	if m.zone == nil {
+		if g.now().Sub(g.roundStart) < g.settings.timeLimit(roundTimeLimit) {
+			return
+		}
+		xPos, yPos := g.mapData.randomOpenPoint(g.rng)
+		m.zone = NewSafeZone(xPos, yPos, g.now())
+		fmt.Fprintln(g.logger, "Sudden death started in game ", g.id)
+		g.sendInfo([]byte(fmt.Sprintf("SuddenDeath::%s", m.zone)))
+	}
+
+	m.zone.shrink(g.now())
+	for _, player := range g.playersById() {
+		if player.alive && !m.zone.contains(player.xPos, player.yPos) {
+			player.damage(zoneDamage)
+		}
+	}
+}
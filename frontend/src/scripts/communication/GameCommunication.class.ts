@@ -57,6 +57,7 @@ export class GameCommunication implements GameCommunicationInterface {
     ) {
         this.gameinfoWebSocket = new WebSocketSubject({
             url: gameinfoAddress,
+            serializer: value => value,
             deserializer: packet => packet.data
         });
     }
@@ -69,6 +70,7 @@ export class GameCommunication implements GameCommunicationInterface {
         return new Promise((resolve, reject) => {
             this.gameinfoWebSocket.subscribe(packet => {
                 const result = this.parseGameinfo(packet);
+                if (!result) return; // commands this frontend doesn't know about yet
                 this.gameinfoSubject.next(result);
 
                 if (result.command === GameinfoCommand.NEW_GAME) {
@@ -94,6 +96,8 @@ export class GameCommunication implements GameCommunicationInterface {
             const parsed = this.parseGameplay(packet);
             this.gameplaySubject.next(parsed);
         });
+        // There is no ready toggle on the controllers yet, so the host starts regardless
+        this.gameinfoWebSocket.next('Start::force');
     }
 
     /**
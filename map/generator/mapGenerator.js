@@ -6,6 +6,7 @@ class MapGenerator {
         this.height = height
         this.map = [...Array(width)].map(x => Array(height).fill(0))
         this.randomFillPercent = 50
+        this.random = Math.random
     }
 
     generateMap() {
@@ -267,7 +268,7 @@ class MapGenerator {
 					this.map[x][y] = 1;
 				}
 				else {
-					this.map[x][y] = (Math.random() * 100 < this.randomFillPercent)? 1: 0;
+					this.map[x][y] = (this.random() * 100 < this.randomFillPercent)? 1: 0;
 				}
             }
         }
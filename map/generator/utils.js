@@ -27,7 +27,19 @@ Queue.prototype.dequeue = function() {
     }
 };
 
+// Returns a function generating numbers in [0, 1) like Math.random, always the same ones for the same seed (mulberry32)
+function seededRandom(seed) {
+    var state = seed >>> 0;
+    return function() {
+        state = (state + 0x6D2B79F5) >>> 0;
+        var t = state;
+        t = Math.imul(t ^ (t >>> 15), t | 1);
+        t ^= t + Math.imul(t ^ (t >>> 7), t | 61);
+        return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+    };
+}
 
 module.exports = {
-    Queue
+    Queue,
+    seededRandom
 }
\ No newline at end of file
@@ -2,6 +2,7 @@ const express = require('express')
 const app = express()
 const MapGenerator = require('./generator/mapGenerator.js')
 const MeshGenerator = require('./generator/meshGenerator.js')
+const { seededRandom } = require('./generator/utils.js')
 const port = 3000
 
 function isNeighbourWall(x, y, map) {
@@ -18,9 +19,9 @@ function isNeighbourWall(x, y, map) {
 
 app.get('/', (req, res) => res.send('You can generate a random map using this service'))
 app.get('/generate', (req, res) => {
-    var { width, height, fillPercentage } = req.query
+    var { width, height, fillPercentage, seed } = req.query
 
-    if (!width || !height || !fillPercentage || isNaN(width) || isNaN(height) || isNaN(fillPercentage)) {
+    if (!width || !height || !fillPercentage || isNaN(width) || isNaN(height) || isNaN(fillPercentage) || (seed !== undefined && isNaN(seed))) {
         res.status(400).json({
             map: null,
             walls: null,
@@ -29,6 +30,10 @@ app.get('/generate', (req, res) => {
     } else {
         var map = new MapGenerator(parseInt(width), parseInt(height))
         map.randomFillPercent = parseInt(fillPercentage)
+        if (seed !== undefined) {
+            // The same seed always generates the same map
+            map.random = seededRandom(parseInt(seed))
+        }
         map.generateMap()
         var mesh = new MeshGenerator(map.map, 1.0 / map.width)
         var outlines = mesh.calculateOutlines()